@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Pre-defined annotation keys whose values have a well-known format.
+// ref. https://github.com/opencontainers/image-spec/blob/main/annotations.md#pre-defined-annotation-keys
+const (
+	annotationKeySource   = "org.opencontainers.image.source"
+	annotationKeyURL      = "org.opencontainers.image.url"
+	annotationKeyRevision = "org.opencontainers.image.revision"
+)
+
+// defaultNoteKey is the annotation key of the free-text note given with --note.
+const defaultNoteKey = "org.otms61.referrer.note"
+
+// revisionPattern matches a commit hash, from the abbreviated to the full SHA-256 one.
+var revisionPattern = regexp.MustCompile(`^[0-9a-f]{7,64}$`)
+
+// parseAnnotations parses the annotations given in the key=value format.
+func parseAnnotations(kvs []string) (map[string]string, error) {
+	anns := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid annotation %q: must be in the key=value format", kv)
+		}
+		anns[k] = v
+	}
+
+	return anns, nil
+}
+
+// annotationsFromEnv returns the annotations from the environment variables in the key=value format starting with the prefix.
+// The keys are lowercased with the underscores replaced by dots, e.g. CI_COMMIT_SHA becomes ci.commit.sha.
+func annotationsFromEnv(environ []string, prefix string) map[string]string {
+	anns := make(map[string]string)
+	for _, kv := range environ {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		anns[strings.ReplaceAll(strings.ToLower(k), "_", ".")] = v
+	}
+
+	return anns
+}
+
+// loadAnnotationsFile loads the annotations from the JSON or YAML file of a flat map of strings.
+func loadAnnotationsFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading annotations file: %w", err)
+	}
+
+	// JSON is parsed as YAML as well.
+	var anns map[string]string
+	if err := yaml.Unmarshal(b, &anns); err != nil {
+		return nil, fmt.Errorf("error parsing annotations file %s: must be a flat map of strings: %w", path, err)
+	}
+
+	if anns == nil {
+		anns = make(map[string]string)
+	}
+	for k := range anns {
+		if k == "" {
+			return nil, fmt.Errorf("invalid annotations file %s: empty key", path)
+		}
+	}
+
+	return anns, nil
+}
+
+// checkAnnotations warns about the malformed values of the pre-defined annotations,
+// so that the consumers get well-formed standard annotations.
+func checkAnnotations(anns map[string]string) {
+	keys := make([]string, 0, len(anns))
+	for k := range anns {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := anns[k]
+		switch k {
+		case annotationKeySource, annotationKeyURL:
+			if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+				log.Logger.Warnf("The annotation %s should be an absolute URL, but got %q", k, v)
+			}
+		case annotationKeyRevision:
+			if !revisionPattern.MatchString(v) {
+				log.Logger.Warnf("The annotation %s should be a commit hash, but got %q", k, v)
+			}
+		}
+	}
+}
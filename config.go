@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const configFileName = ".trivy-referrer.yaml"
+
+// findConfig returns the config file given with --config,
+// or .trivy-referrer.yaml in the current directory or the home directory if any.
+func findConfig(cmd *cobra.Command) (string, error) {
+	path, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return "", fmt.Errorf("error getting config flag: %w", err)
+	}
+	if path != "" {
+		return path, nil
+	}
+
+	dirs := []string{"."}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, home)
+	}
+	for _, dir := range dirs {
+		p := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+
+	return "", nil
+}
+
+// bindConfig sets the flags given neither on the command line nor with the environment variables from the config file.
+// The keys are the flag names, and the flags that can be specified multiple times take a list.
+func bindConfig(cmd *cobra.Command) error {
+	path, err := findConfig(cmd)
+	if err != nil || path == "" {
+		return err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		value := values[key]
+		f := cmd.Flags().Lookup(key)
+		if f == nil || key == "config" || key == "help" {
+			// The config file is shared by the subcommands, which have different flags.
+			log.Logger.Debugf("Ignoring %s in the config file %s, not a flag of %s", key, path, cmd.Name())
+			continue
+		}
+		if f.Changed {
+			continue
+		}
+
+		items, ok := value.([]any)
+		if !ok {
+			items = []any{value}
+		}
+		for _, item := range items {
+			switch item.(type) {
+			case map[string]any, []any, nil:
+				errs = append(errs, fmt.Errorf("invalid value of %s in the config file %s: must be a scalar or a list of scalars", key, path))
+				continue
+			}
+			if err := cmd.Flags().Set(key, fmt.Sprint(item)); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q of %s in the config file %s: %w", fmt.Sprint(item), key, path, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// referrerFromImage rebuilds the referrer from the pushed referrer image, attaching it to the given subject.
+// The annotations, the artifact type, the layer media type and title, and the manifest style and media type are kept.
+func referrerFromImage(img v1.Image, subject name.Digest, desc v1.Descriptor) (*referrer.Referrer, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("error getting manifest: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("error getting layers: %w", err)
+	}
+	if len(layers) != 1 {
+		return nil, fmt.Errorf("referrer has %d layers, but only referrers with a single layer are supported", len(layers))
+	}
+
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return nil, fmt.Errorf("error reading layer: %w", err)
+	}
+	defer rc.Close()
+
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading layer: %w", err)
+	}
+
+	annotations := make(map[string]string, len(manifest.Annotations))
+	for k, v := range manifest.Annotations {
+		annotations[k] = v
+	}
+
+	// The manifest of the image style has the artifact type in the artifactType field, which v1.Manifest doesn't have.
+	raw, err := img.RawManifest()
+	if err != nil {
+		return nil, fmt.Errorf("error getting manifest: %w", err)
+	}
+	var fields struct {
+		ArtifactType ctypes.MediaType `json:"artifactType"`
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding manifest: %w", err)
+	}
+
+	ref := &referrer.Referrer{
+		Annotations:  annotations,
+		MediaType:    manifest.Layers[0].MediaType,
+		ArtifactType: manifest.Config.MediaType,
+		Bytes:        b,
+		Title:        manifest.Layers[0].Annotations[referrer.AnnotationKeyTitle],
+		TargetRepo:   subject,
+		TargetDesc:   desc,
+	}
+	if manifest.Config.MediaType == referrer.MediaKeyEmpty {
+		ref.ArtifactType = fields.ArtifactType
+		ref.ManifestStyle = referrer.ManifestStyleImage
+	} else {
+		// The media type would otherwise follow the destination subject instead of --image-media-type of the source.
+		ref.ManifestMediaType = manifest.MediaType
+		// The artifact style manifest pushed with --empty-config has `{}` of the artifact type as the config.
+		ref.EmptyConfig = referrer.IsEmptyConfig(manifest.Config)
+	}
+	// The layer pushed with --compress has the gzip media type instead of the one of the content.
+	if ref.MediaType == ctypes.OCILayer {
+		ref.MediaType = ref.ArtifactType
+		ref.Compress = true
+	}
+
+	return ref, nil
+}
+
+// copyReferrers pushes the referrers attached to src again with the subject replaced by opts.Subject.
+// Referrers already attached to dst are skipped.
+func copyReferrers(ctx context.Context, src string, opts referrer.Options) error {
+	srcDigest, err := referrer.SubjectDigest(ctx, src, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error resolving source: %w", err)
+	}
+
+	dstDigest, dstDesc, err := opts.ResolveSubject(ctx)
+	if err != nil {
+		return fmt.Errorf("error resolving destination: %w", err)
+	}
+
+	index, err := referrer.FetchReferrers(ctx, srcDigest, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error fetching referrers: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		log.Logger.Infof("No referrer found for %s", srcDigest.String())
+		return nil
+	}
+
+	names := make([]string, len(index.Manifests))
+	errs := make([]error, len(index.Manifests))
+	for i, desc := range index.Manifests {
+		srcRef := srcDigest.Context().Digest(desc.Digest.String())
+		names[i] = "from " + srcRef.String()
+		errs[i] = copyReferrer(ctx, srcRef, dstDigest, dstDesc, opts)
+	}
+
+	return summarize("copy", names, errs)
+}
+
+// copyReferrer copies the referrer to the destination subject unless an identical one is already attached.
+func copyReferrer(ctx context.Context, srcRef, dstDigest name.Digest, dstDesc v1.Descriptor, opts referrer.Options) error {
+	var img v1.Image
+	err := opts.Retry(ctx, func() (err error) {
+		img, err = remote.Image(srcRef, opts.RemoteOptions(ctx)...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching referrer %s: %w", srcRef.String(), err)
+	}
+
+	ref, err := referrerFromImage(img, dstDigest, dstDesc)
+	if err != nil {
+		return fmt.Errorf("error reading referrer %s: %w", srcRef.String(), err)
+	}
+
+	dstImg, err := ref.Image()
+	if err != nil {
+		return fmt.Errorf("error getting image: %w", err)
+	}
+
+	tag, err := ref.Tag(dstImg)
+	if err != nil {
+		return fmt.Errorf("error getting tag: %w", err)
+	}
+
+	existing, exists, err := referrerExists(ctx, dstDigest, tag, dstImg, string(artifactTypeOf(ref)), opts.RegistryOptions)
+	if err != nil {
+		return err
+	}
+	if exists {
+		log.Logger.Infof("Referrer %s with the same content is already present, skipping the copy", existing.String())
+		return nil
+	}
+
+	if err := referrer.Push(ctx, ref, opts); err != nil {
+		return fmt.Errorf("error copying referrer %s: %w", srcRef.String(), err)
+	}
+	log.Logger.Infof("Copied referrer %s to %s", srcRef.String(), tag.String())
+
+	return nil
+}
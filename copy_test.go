@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// referrerManifest has the fields of the referrer manifest kept by copy.
+type referrerManifest struct {
+	MediaType    ctypes.MediaType `json:"mediaType"`
+	ArtifactType ctypes.MediaType `json:"artifactType"`
+	Config       struct {
+		MediaType ctypes.MediaType `json:"mediaType"`
+	} `json:"config"`
+}
+
+// fetchOnlyReferrer returns the manifest of the only referrer of the subject.
+func fetchOnlyReferrer(t *testing.T, subject name.Digest) referrerManifest {
+	t.Helper()
+	index, err := referrer.FetchReferrers(context.Background(), subject, referrer.RegistryOptions{})
+	if err != nil {
+		t.Fatalf("error fetching referrers: %s", err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Fatalf("got %d referrers of %s, want 1", len(index.Manifests), subject)
+	}
+
+	desc, err := remote.Get(subject.Context().Digest(index.Manifests[0].Digest.String()))
+	if err != nil {
+		t.Fatalf("error fetching referrer: %s", err)
+	}
+	var manifest referrerManifest
+	if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+		t.Fatalf("error parsing manifest: %s", err)
+	}
+	return manifest
+}
+
+func TestCopyReferrersRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts putOptions
+	}{
+		{
+			name: "default",
+		},
+		{
+			// The subjects are Docker images, whose referrers are Docker image manifests by default.
+			name: "OCI image media type",
+			opts: putOptions{manifestMediaType: string(ctypes.OCIManifestSchema1)},
+		},
+		{
+			name: "empty config",
+			opts: putOptions{manifestMediaType: string(ctypes.OCIManifestSchema1), emptyConfig: true},
+		},
+		{
+			name: "image style",
+			opts: putOptions{manifestStyle: referrer.ManifestStyleImage},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			host := newTestRegistry(t)
+			src := pushTestImage(t, host, "app")
+			dst := pushTestImage(t, host, "mirror")
+
+			if err := putReferrer(ctx, strings.NewReader(loadFixture(t, "cyclonedx.json", src)), io.Discard, tt.opts); err != nil {
+				t.Fatalf("error putting referrer: %s", err)
+			}
+			if err := copyReferrers(ctx, src.String(), referrer.Options{Subject: dst.String()}); err != nil {
+				t.Fatalf("error copying referrers: %s", err)
+			}
+
+			if got, want := fetchOnlyReferrer(t, dst), fetchOnlyReferrer(t, src); got != want {
+				t.Errorf("got copied manifest %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+type deleteOptions struct {
+	referrer.RegistryOptions
+	mediaType string
+	digest    string
+	all       bool
+}
+
+// matchReferrers returns the referrers of the subject selected by the options.
+// Unless --all is given, exactly one referrer must match.
+func matchReferrers(ctx context.Context, subject name.Digest, opts deleteOptions) ([]v1.Descriptor, error) {
+	if opts.mediaType == "" && opts.digest == "" && !opts.all {
+		return nil, fmt.Errorf("either --media-type, --digest or --all is required to select the referrers to delete")
+	}
+
+	index, err := referrer.FetchReferrers(ctx, subject, opts.RegistryOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	var matched []v1.Descriptor
+	for _, desc := range index.Manifests {
+		if opts.mediaType != "" && desc.ArtifactType != opts.mediaType {
+			continue
+		}
+		if opts.digest != "" && desc.Digest.String() != opts.digest {
+			continue
+		}
+		matched = append(matched, desc)
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%w for %s with the given filters", errNoReferrer, subject.String())
+	}
+	if len(matched) > 1 && !opts.all {
+		return nil, fmt.Errorf("%d referrers match for %s, specify --digest or use --all to delete all of them", len(matched), subject.String())
+	}
+
+	return matched, nil
+}
+
+// removeFromFallbackTag removes the deleted referrers from the index tracked with the fallback tag.
+// Registries with the referrers API update the referrers by themselves.
+func removeFromFallbackTag(ctx context.Context, subject name.Digest, digests []v1.Hash, opts referrer.RegistryOptions) error {
+	tag := referrer.FallbackTag(subject)
+
+	var idx v1.ImageIndex
+	err := opts.Retry(ctx, func() (err error) {
+		idx, err = remote.Index(tag, opts.RemoteOptions(ctx)...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching fallback tag: %w", err)
+	}
+
+	idx = mutate.RemoveManifests(idx, match.Digests(digests...))
+	err = opts.Retry(ctx, func() error {
+		return remote.WriteIndex(tag, idx, opts.RemoteOptions(ctx)...)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating fallback tag: %w", err)
+	}
+
+	return nil
+}
+
+func deleteReferrers(ctx context.Context, subject string, opts deleteOptions) error {
+	digest, err := referrer.SubjectDigest(ctx, subject, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error resolving subject: %w", err)
+	}
+
+	matched, err := matchReferrers(ctx, digest, opts)
+	if err != nil {
+		return err
+	}
+
+	return deleteDescriptors(ctx, digest, matched, opts.RegistryOptions)
+}
+
+// deleteDescriptors deletes the referrers of the subject, removing them from the fallback tag as well if needed.
+func deleteDescriptors(ctx context.Context, digest name.Digest, matched []v1.Descriptor, opts referrer.RegistryOptions) error {
+	var deleted []v1.Hash
+	for _, desc := range matched {
+		ref := digest.Context().Digest(desc.Digest.String())
+		err := opts.Retry(ctx, func() error {
+			return remote.Delete(ref, opts.RemoteOptions(ctx)...)
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting referrer %s: %w", ref.String(), err)
+		}
+		log.Logger.Infof("Deleted referrer %s", ref.String())
+		deleted = append(deleted, desc.Digest)
+	}
+
+	supported, err := referrer.ReferrersAPISupported(ctx, digest, opts)
+	if err != nil {
+		return fmt.Errorf("error checking referrers API: %w", err)
+	}
+	if !supported {
+		if err := removeFromFallbackTag(ctx, digest, deleted, opts); err != nil {
+			return err
+		}
+		log.Logger.Infof("Removed the deleted referrers from the fallback tag %s", referrer.FallbackTag(digest).String())
+	}
+
+	return nil
+}
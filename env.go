@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const envPrefix = "TRIVY_REFERRER_"
+
+// flagEnv returns the environment variable for the flag, such as TRIVY_REFERRER_MAX_RETRIES for --max-retries.
+func flagEnv(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// bindEnv sets the flags not given on the command line from the environment variables,
+// so that the flags given explicitly take precedence.
+func bindEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+
+		v, ok := os.LookupEnv(flagEnv(f.Name))
+		if !ok {
+			return
+		}
+		if serr := cmd.Flags().Set(f.Name, v); serr != nil {
+			err = fmt.Errorf("invalid value %q of %s: %w", v, flagEnv(f.Name), serr)
+		}
+	})
+
+	return err
+}
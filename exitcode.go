@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Exit codes for the failure classes, so that CI jobs can tell them apart.
+const (
+	exitCodeError    = 1
+	exitCodeInput    = 2
+	exitCodeAuth     = 3
+	exitCodeNetwork  = 4
+	exitCodeNotFound = 5
+)
+
+// errNoReferrer is returned when no referrer matches.
+var errNoReferrer = errors.New("no referrer found")
+
+// inputError marks the error caused by the input, such as an SBOM failing to be parsed.
+type inputError struct {
+	err error
+}
+
+func (e *inputError) Error() string {
+	return e.err.Error()
+}
+
+func (e *inputError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code for the error.
+// The registry errors take precedence, since reading the input may involve the registry, e.g. to resolve the subject.
+func exitCode(err error) int {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		switch {
+		case terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden:
+			return exitCodeAuth
+		case terr.StatusCode == http.StatusNotFound:
+			return exitCodeNotFound
+		case terr.StatusCode == http.StatusTooManyRequests || terr.StatusCode >= http.StatusInternalServerError:
+			return exitCodeNetwork
+		}
+	}
+	if errors.Is(err, errNoReferrer) {
+		return exitCodeNotFound
+	}
+
+	// net.Error isn't checked, since syscall.Errno of file errors implements it as well.
+	var uerr *url.Error
+	var operr *net.OpError
+	if errors.As(err, &uerr) || errors.As(err, &operr) || errors.Is(err, context.DeadlineExceeded) {
+		return exitCodeNetwork
+	}
+
+	var ierr *inputError
+	if errors.As(err, &ierr) {
+		return exitCodeInput
+	}
+
+	return exitCodeError
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+func findReferrer(ctx context.Context, subject name.Digest, mediaType string, opts referrer.RegistryOptions) (v1.Descriptor, error) {
+	index, err := referrer.FetchReferrers(ctx, subject, opts)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	var matched []v1.Descriptor
+	for _, desc := range index.Manifests {
+		if mediaType == "" || desc.ArtifactType == mediaType {
+			matched = append(matched, desc)
+		}
+	}
+
+	if len(matched) == 0 {
+		if mediaType != "" {
+			return v1.Descriptor{}, fmt.Errorf("%w for %s with media type %s", errNoReferrer, subject.String(), mediaType)
+		}
+		return v1.Descriptor{}, fmt.Errorf("%w for %s", errNoReferrer, subject.String())
+	}
+	if len(matched) > 1 {
+		log.Logger.Warnf("%d referrers found for %s, using %s", len(matched), subject.String(), matched[0].Digest.String())
+	}
+
+	return matched[0], nil
+}
+
+func getReferrer(ctx context.Context, subject, mediaType string, w io.Writer, opts referrer.RegistryOptions) error {
+	digest, err := referrer.SubjectDigest(ctx, subject, opts)
+	if err != nil {
+		return fmt.Errorf("error resolving subject: %w", err)
+	}
+
+	desc, err := findReferrer(ctx, digest, mediaType, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeReferrer(ctx, digest, desc, w, opts)
+}
+
+// writeReferrer writes the content of the referrer of the subject to w.
+func writeReferrer(ctx context.Context, digest name.Digest, desc v1.Descriptor, w io.Writer, opts referrer.RegistryOptions) error {
+	log.Logger.Infof("Getting referrer %s", desc.Digest.String())
+
+	img, err := remote.Image(digest.Context().Digest(desc.Digest.String()), opts.RemoteOptions(ctx)...)
+	if err != nil {
+		return fmt.Errorf("error fetching referrer: %w", err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("error getting layers: %w", err)
+	}
+	if len(layers) == 0 {
+		return fmt.Errorf("referrer %s has no layers", desc.Digest.String())
+	}
+
+	// The layer pushed with --compress is decompressed as well.
+	rc, err := layers[0].Uncompressed()
+	if err != nil {
+		return fmt.Errorf("error reading layer: %w", err)
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return fmt.Errorf("error writing referrer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+func isDataURI(path string) bool {
+	return strings.HasPrefix(path, "data:")
+}
+
+// decodeDataURI returns the data of the data URI, such as data:application/json;base64,eyJ...
+func decodeDataURI(uri string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URI: missing comma")
+	}
+
+	if strings.HasSuffix(meta, ";base64") {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding base64 data URI: %w", err)
+		}
+		return b, nil
+	}
+
+	s, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding data URI: %w", err)
+	}
+	return []byte(s), nil
+}
+
+// inputTitle returns the file name of the input for the given file path or URL.
+func inputTitle(input string) string {
+	if isDataURI(input) {
+		return ""
+	}
+	if isURL(input) {
+		u, err := url.Parse(input)
+		if err != nil || u.Path == "" || u.Path == "/" {
+			return ""
+		}
+		return path.Base(u.Path)
+	}
+	return filepath.Base(input)
+}
+
+// openInput opens the input for the given path.
+// The path can be a local file path, an HTTP(S) URL or a data URI.
+// Named pipes and devices are read fully, up to one byte more than maxSize unless it is zero,
+// so that the size limit is checked without buffering unboundedly.
+// The download of a URL is canceled with ctx, such as on the timeout.
+func openInput(ctx context.Context, path string, maxSize int64) (io.ReadCloser, error) {
+	if isDataURI(path) {
+		b, err := decodeDataURI(path)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	if isURL(path) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request for %s: %w", path, err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching %s: %w", path, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error fetching %s: unexpected status code %d", path, resp.StatusCode)
+		}
+
+		return resp.Body, nil
+	}
+
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	fi, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, fmt.Errorf("error getting file info: %w", err)
+	}
+
+	switch {
+	case fi.Mode().IsRegular():
+		return fp, nil
+	case fi.IsDir():
+		fp.Close()
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	// Read named pipes and devices such as /dev/stdin until the writer closes them,
+	// so that the input is complete before it is processed.
+	defer fp.Close()
+	var r io.Reader = fp
+	if maxSize > 0 {
+		r = io.LimitReader(fp, maxSize+1)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// pipeInput returns the path of the read end of a pipe written with data in the background.
+func pipeInput(t *testing.T, data string) string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("the pipe is opened with /dev/fd, which is tested only on Linux")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error creating pipe: %s", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		defer w.Close()
+		// The write fails once the reader stops reading after the size limit.
+		_, _ = io.WriteString(w, data)
+	}()
+
+	return fmt.Sprintf("/dev/fd/%d", r.Fd())
+}
+
+func TestOpenInputPipe(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		maxSize int64
+		want    string
+	}{
+		{
+			name: "read until the writer closes",
+			data: strings.Repeat("a", 1<<20),
+			want: strings.Repeat("a", 1<<20),
+		},
+		{
+			name:    "read up to one byte more than the limit",
+			data:    strings.Repeat("a", 1<<20),
+			maxSize: 10,
+			want:    strings.Repeat("a", 11),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := openInput(context.Background(), pipeInput(t, tt.data), tt.maxSize)
+			if err != nil {
+				t.Fatalf("openInput() error = %s", err)
+			}
+			defer rc.Close()
+
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("error reading input: %s", err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("read %d bytes, want %d", len(b), len(tt.want))
+			}
+		})
+	}
+}
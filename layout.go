@@ -0,0 +1,203 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// annotationKeyRefName records the digest reference to push the referrer to in index.json of the layout.
+// ref. https://github.com/opencontainers/image-spec/blob/main/image-layout.md#indexjson-file
+const annotationKeyRefName = "org.opencontainers.image.ref.name"
+
+// exportLayout writes the referrer image to the tarball of an OCI image layout, to be pushed to the digest reference later.
+func exportLayout(path string, tag name.Digest, img v1.Image) error {
+	dir, err := os.MkdirTemp("", "trivy-referrer-layout-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, err := layout.Write(dir, empty.Index)
+	if err != nil {
+		return fmt.Errorf("error creating layout: %w", err)
+	}
+	err = p.AppendImage(img, layout.WithAnnotations(map[string]string{annotationKeyRefName: tag.String()}))
+	if err != nil {
+		return fmt.Errorf("error writing image to layout: %w", err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating export file: %w", err)
+	}
+	if err := tarDir(f, dir); err != nil {
+		f.Close()
+		return fmt.Errorf("error archiving layout: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error closing export file: %w", err)
+	}
+
+	return nil
+}
+
+// tarDir writes the files under the directory to w as a tar archive.
+func tarDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// untar extracts the directories and the regular files of the tar archive into the directory.
+func untar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !filepath.IsLocal(hdr.Name) {
+			return fmt.Errorf("invalid path in the archive: %s", hdr.Name)
+		}
+		path := filepath.Join(dir, hdr.Name)
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pushLayout pushes the referrer images in the tarball of the OCI image layout written with --export.
+// The pushed references are printed to w unless quiet is set.
+func pushLayout(ctx context.Context, path string, w io.Writer, quiet bool, opts referrer.Options) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening layout: %w", err)
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "trivy-referrer-layout-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := untar(f, dir); err != nil {
+		return fmt.Errorf("error extracting layout: %w", err)
+	}
+
+	p, err := layout.FromPath(dir)
+	if err != nil {
+		return fmt.Errorf("error reading layout: %w", err)
+	}
+	idx, err := p.ImageIndex()
+	if err != nil {
+		return fmt.Errorf("error reading layout index: %w", err)
+	}
+	index, err := idx.IndexManifest()
+	if err != nil {
+		return fmt.Errorf("error reading layout index: %w", err)
+	}
+	if len(index.Manifests) == 0 {
+		return fmt.Errorf("no referrer found in the layout")
+	}
+
+	for _, desc := range index.Manifests {
+		refName := desc.Annotations[annotationKeyRefName]
+		if refName == "" {
+			return fmt.Errorf("the image %s in the layout has no %s annotation to push it to", desc.Digest.String(), annotationKeyRefName)
+		}
+		tag, err := name.NewDigest(refName, opts.NameOptions()...)
+		if err != nil {
+			return fmt.Errorf("invalid reference %s in the layout: %w", refName, err)
+		}
+		if tag.DigestStr() != desc.Digest.String() {
+			return fmt.Errorf("the reference %s doesn't match the digest %s of the image in the layout", refName, desc.Digest.String())
+		}
+
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return fmt.Errorf("error reading image %s from layout: %w", desc.Digest.String(), err)
+		}
+		if err := referrer.PushImage(ctx, tag, img, opts); err != nil {
+			return err
+		}
+		log.Logger.Infof("Pushed referrer %s from the layout", tag.String())
+		if !quiet {
+			fmt.Fprintf(w, "pushed: %s\n", tag.String())
+		}
+	}
+
+	return nil
+}
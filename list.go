@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+)
+
+func writeReferrersTable(index *v1.IndexManifest, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIGEST\tTYPE\tSIZE\tDESCRIPTION")
+	for _, desc := range index.Manifests {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n",
+			desc.Digest.String(), desc.ArtifactType, desc.Size, desc.Annotations[referrer.AnnotationKeyDescription])
+	}
+	return tw.Flush()
+}
+
+func listReferrers(ctx context.Context, subject, format string, w io.Writer, opts referrer.RegistryOptions) error {
+	digest, err := referrer.SubjectDigest(ctx, subject, opts)
+	if err != nil {
+		return fmt.Errorf("error resolving subject: %w", err)
+	}
+
+	index, err := referrer.FetchReferrers(ctx, digest, opts)
+	if err != nil {
+		return fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	switch format {
+	case listFormatTable:
+		if err := writeReferrersTable(index, w); err != nil {
+			return fmt.Errorf("error writing table: %w", err)
+		}
+	case listFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(index); err != nil {
+			return fmt.Errorf("error encoding referrers: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	return nil
+}
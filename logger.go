@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevels are the levels of --log-level. zapcore also parses dpanic, panic and fatal, which aren't levels to filter the logs with.
+var logLevels = map[string]zapcore.Level{
+	"debug": zapcore.DebugLevel,
+	"info":  zapcore.InfoLevel,
+	"warn":  zapcore.WarnLevel,
+	"error": zapcore.ErrorLevel,
+}
+
+// initLogger configures log.Logger.
+// The logger of Trivy is used for the text format unless the level is given explicitly.
+func initLogger(debug, quiet bool, format, level string) error {
+	if format == logFormatText && level == "" {
+		return log.InitLogger(debug, quiet)
+	}
+
+	lvl := zapcore.InfoLevel
+	switch {
+	case level != "":
+		l, ok := logLevels[strings.ToLower(level)]
+		if !ok {
+			return fmt.Errorf("invalid log level %q: must be one of debug, info, warn, error", level)
+		}
+		lvl = l
+	case debug:
+		lvl = zapcore.DebugLevel
+	case quiet:
+		lvl = zapcore.ErrorLevel
+	}
+
+	encoderConfig := zap.NewProductionEncoderConfig()
+	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var encoder zapcore.Encoder
+	switch format {
+	case logFormatJSON:
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case logFormatText:
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return fmt.Errorf("unsupported log format: %s", format)
+	}
+
+	core := zapcore.NewCore(encoder, zapcore.Lock(os.Stderr), lvl)
+	log.Logger = zap.New(core, zap.ErrorOutput(zapcore.Lock(os.Stderr))).Sugar()
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+)
+
+func TestInitLoggerLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		wantErr bool
+	}{
+		{level: "debug"},
+		{level: "info"},
+		{level: "WARN"},
+		{level: "error"},
+		{level: "dpanic", wantErr: true},
+		{level: "panic", wantErr: true},
+		{level: "fatal", wantErr: true},
+		{level: "verbose", wantErr: true},
+	}
+
+	logger := log.Logger
+	t.Cleanup(func() { log.Logger = logger })
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			err := initLogger(false, false, logFormatJSON, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("initLogger() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
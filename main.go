@@ -1,336 +1,791 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/aquasecurity/trivy/pkg/log"
-	"github.com/aquasecurity/trivy/pkg/purl"
-	"github.com/aquasecurity/trivy/pkg/report/predicate"
-	"github.com/aquasecurity/trivy/pkg/sbom"
-	"github.com/google/go-containerregistry/pkg/authn"
-	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
-	"github.com/google/go-containerregistry/pkg/v1/empty"
-	"github.com/google/go-containerregistry/pkg/v1/mutate"
-	"github.com/google/go-containerregistry/pkg/v1/remote"
-	"github.com/google/go-containerregistry/pkg/v1/static"
 	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
-	"github.com/spdx/tools-golang/spdx"
 	"github.com/spf13/cobra"
-)
 
-const (
-	// ref. https://github.com/opencontainers/image-spec/blob/dd7fd714f5406d39db5fd0602a0e6090929dc85e/annotations.md#pre-defined-annotation-keys
-	annotationKeyCreated     = "org.opencontainers.artifact.created"
-	annotationKeyDescription = "org.opencontainers.artifact.description"
-
-	// Use a Media Type registered with IANA.
-	// ref. https://github.com/opencontainers/image-spec/blob/dd7fd714f5406d39db5fd0602a0e6090929dc85e/artifact.md#artifact-manifest-property-descriptions
-	// ref. https://www.iana.org/assignments/media-types/media-types.xhtml
-	mediaKeyCycloneDX = "application/vnd.cyclonedx+json"
-	mediaKeySPDX      = "application/spdx+json"
-	// 2023/4/4: Since there is no MediaType specialized for vulnerability information registered with IANA, we use the json type.
-	mediaKeyCosignVuln = "application/json"
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
 )
 
-var errFailedSBOMDetection = fmt.Errorf("failed to detect SBOM")
-var errFailedVulnDetection = fmt.Errorf("failed to detect Cosign Vulnerability")
+func main() {
+	rootCmd := &cobra.Command{
+		Short:   "A Trivy plugin for oci referrers",
+		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := bindEnv(cmd); err != nil {
+				return err
+			}
+			if err := bindConfig(cmd); err != nil {
+				return err
+			}
 
-type referrer struct {
-	annotations map[string]string
-	mediaType   ctypes.MediaType
-	bytes       []byte
-	targetRepo  name.Digest
-	targetDesc  v1.Descriptor
-}
+			debug, err := cmd.Flags().GetBool("debug")
+			if err != nil {
+				return fmt.Errorf("error getting debug flag: %w", err)
+			}
 
-func (r *referrer) Image() (v1.Image, error) {
-	img, err := mutate.Append(empty.Image, mutate.Addendum{
-		Layer: static.NewLayer(r.bytes, r.mediaType),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("error appending layer: %w", err)
-	}
+			quiet, err := cmd.Flags().GetBool("quiet")
+			if err != nil {
+				return fmt.Errorf("error getting quiet flag: %w", err)
+			}
+			if quiet {
+				// The error is still logged on exit, without the usage.
+				cmd.SilenceUsage = true
+				cmd.SilenceErrors = true
+			}
 
-	img = mutate.MediaType(img, r.targetDesc.MediaType)
-	img = mutate.ConfigMediaType(img, r.mediaType)
-	img = mutate.Annotations(img, r.annotations).(v1.Image)
-	img = mutate.Subject(img, r.targetDesc).(v1.Image)
+			logFormat, err := cmd.Flags().GetString("log-format")
+			if err != nil {
+				return fmt.Errorf("error getting log format: %w", err)
+			}
 
-	return img, nil
-}
+			logLevel, err := cmd.Flags().GetString("log-level")
+			if err != nil {
+				return fmt.Errorf("error getting log level: %w", err)
+			}
 
-func (r *referrer) Tag(img v1.Image) (name.Digest, error) {
-	digest, err := img.Digest()
-	if err != nil {
-		return name.Digest{}, fmt.Errorf("error getting image digest: %w", err)
-	}
+			if err := initLogger(debug, quiet, logFormat, logLevel); err != nil {
+				return err
+			}
 
-	tag, err := name.NewDigest(
-		fmt.Sprintf("%s/%s@%s", r.targetRepo.RegistryStr(), r.targetRepo.RepositoryStr(), digest.String()),
-	)
-	if err != nil {
-		return name.Digest{}, fmt.Errorf("error creating new digest: %w", err)
+			return nil
+		},
 	}
-	return tag, nil
-}
+	rootCmd.PersistentFlags().BoolP("debug", "d", false, "debug mode")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "suppress all output except errors")
+	rootCmd.PersistentFlags().String("log-format", logFormatText, "log format (text, json)")
+	rootCmd.PersistentFlags().String("config", "", "config file of the default flags (default .trivy-referrer.yaml in the current or the home directory)")
+	rootCmd.PersistentFlags().String("log-level", "", "log level (debug, info, warn, error). Overrides --debug and --quiet.")
 
-func repoFromPurl(purlStr string) (name.Digest, error) {
-	p, err := purl.FromString(purlStr)
-	if err != nil {
-		return name.Digest{}, fmt.Errorf("error parsing purl: %w", err)
-	}
+	putCmd := &cobra.Command{
+		Use:   "put [FILE...]",
+		Short: "put a referrer to the oci registry",
+		Example: `  trivy image -q -f cyclonedx YOUR_IMAGE | trivy referrer put
+  # Put SBOM attestation
+  trivy referrer put -f sbom.json
+  # Put SBOM fetched from a URL
+  trivy referrer put -f https://example.com/sbom.json
+  # Put multiple SBOMs
+  trivy referrer put sbom1.json sbom2.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := cmd.Flags().GetStringArray("file")
+			if err != nil {
+				return fmt.Errorf("error getting file path: %w", err)
+			}
+			paths = append(paths, args...)
 
-	url := p.Qualifiers.Map()["repository_url"]
-	if url == "" {
-		return name.Digest{}, fmt.Errorf("repository_url not found")
-	}
+			if err := checkPasswordStdin(cmd, paths); err != nil {
+				return err
+			}
 
-	digest, err := name.NewDigest(fmt.Sprintf("%s@%s", url, p.Version))
-	if err != nil {
-		return name.Digest{}, fmt.Errorf("error creating new digest: %w", err)
-	}
+			inputOpts, err := inputOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			regOpts := inputOpts.RegistryOptions
 
-	return digest, nil
-}
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return fmt.Errorf("error getting dry-run flag: %w", err)
+			}
+
+			progress, err := cmd.Flags().GetBool("progress")
+			if err != nil {
+				return fmt.Errorf("error getting progress flag: %w", err)
+			}
+
+			stats, err := cmd.Flags().GetBool("stats")
+			if err != nil {
+				return fmt.Errorf("error getting stats flag: %w", err)
+			}
+
+			quiet, err := cmd.Flags().GetBool("quiet")
+			if err != nil {
+				return fmt.Errorf("error getting quiet flag: %w", err)
+			}
+
+			noTimestamp, err := cmd.Flags().GetBool("no-timestamp")
+			if err != nil {
+				return fmt.Errorf("error getting no-timestamp flag: %w", err)
+			}
+
+			reproducible, err := cmd.Flags().GetBool("reproducible")
+			if err != nil {
+				return fmt.Errorf("error getting reproducible flag: %w", err)
+			}
+			if reproducible {
+				noTimestamp = true
+			}
+
+			requireImage, err := cmd.Flags().GetBool("require-image")
+			if err != nil {
+				return fmt.Errorf("error getting require-image flag: %w", err)
+			}
+
+			titleFromSBOM, err := cmd.Flags().GetBool("annotation-title-from-sbom")
+			if err != nil {
+				return fmt.Errorf("error getting annotation-title-from-sbom flag: %w", err)
+			}
+
+			force, err := cmd.Flags().GetBool("force")
+			if err != nil {
+				return fmt.Errorf("error getting force flag: %w", err)
+			}
 
-func repoFromSpdx(spdx spdx.Document2_2) (name.Digest, error) {
-	for _, pkg := range spdx.Packages {
-		if pkg.PackageName == spdx.CreationInfo.DocumentName {
-			for _, ref := range pkg.PackageExternalReferences {
-				if ref.Category == "PACKAGE-MANAGER" {
-					return repoFromPurl(ref.Locator)
+			replaceExisting, err := cmd.Flags().GetBool("replace-existing-media-type")
+			if err != nil {
+				return fmt.Errorf("error getting replace-existing-media-type flag: %w", err)
+			}
+
+			concurrency, err := cmd.Flags().GetInt("concurrency")
+			if err != nil {
+				return fmt.Errorf("error getting concurrency: %w", err)
+			}
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+			if progress && concurrency > 1 {
+				// The progress is printed in a single line, which can't be shared by concurrent pushes.
+				concurrency = 1
+			}
+
+			sign, err := cmd.Flags().GetBool("sign")
+			if err != nil {
+				return fmt.Errorf("error getting sign flag: %w", err)
+			}
+
+			signKey, err := cmd.Flags().GetString("sign-key")
+			if err != nil {
+				return fmt.Errorf("error getting sign key: %w", err)
+			}
+			if sign && signKey == "" {
+				return fmt.Errorf("--sign-key is required when --sign is given")
+			}
+			if !sign {
+				signKey = ""
+			}
+			if sign {
+				if err := referrer.CheckSignOptions(regOpts); err != nil {
+					return err
 				}
 			}
-		}
-	}
 
-	return name.Digest{}, fmt.Errorf("error getting repository from SPDX")
-}
+			description, err := cmd.Flags().GetString("description")
+			if err != nil {
+				return fmt.Errorf("error getting description: %w", err)
+			}
 
-func tryReferrerFromSBOM(r io.Reader) (referrer, error) {
-	b, err := io.ReadAll(r)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error reading: %w", err)
-	}
+			note, err := cmd.Flags().GetString("note")
+			if err != nil {
+				return fmt.Errorf("error getting note: %w", err)
+			}
 
-	format, err := sbom.DetectFormat(bytes.NewReader(b))
-	if format == sbom.FormatUnknown {
-		return referrer{}, errFailedSBOMDetection
-	} else if err != nil {
-		return referrer{}, fmt.Errorf("error detecting SBOM format: %w", err)
-	}
-	decoded, err := sbom.Decode(bytes.NewReader(b), format)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error decoding SBOM: %w", err)
-	}
+			noteKey, err := cmd.Flags().GetString("note-key")
+			if err != nil {
+				return fmt.Errorf("error getting note key: %w", err)
+			}
+			if noteKey == "" {
+				return fmt.Errorf("--note-key must not be empty")
+			}
 
-	var mediaType ctypes.MediaType
-	var anns map[string]string
-	var repo name.Digest
-
-	switch format {
-	case sbom.FormatCycloneDXJSON:
-		repo, err = repoFromPurl(decoded.CycloneDX.Metadata.Component.BOMRef)
-		if err != nil {
-			return referrer{}, fmt.Errorf("error getting repository from CycloneDX: %w", err)
-		}
-		anns = map[string]string{
-			annotationKeyDescription: "CycloneDX JSON SBOM",
-		}
-		mediaType = mediaKeyCycloneDX
-
-	case sbom.FormatSPDXJSON:
-		repo, err = repoFromSpdx(*decoded.SPDX)
-		if err != nil {
-			return referrer{}, fmt.Errorf("error getting repository from SPDX: %w", err)
-		}
-		anns = map[string]string{
-			annotationKeyDescription: "SPDX JSON SBOM",
-		}
-		mediaType = mediaKeySPDX
-
-	default:
-		return referrer{}, fmt.Errorf("unsupported format: %s", format)
-	}
+			artifactType, err := cmd.Flags().GetString("artifact-type")
+			if err != nil {
+				return fmt.Errorf("error getting artifact type: %w", err)
+			}
 
-	log.Logger.Infof("SBOM detected: %s", format)
+			allowMediaTypeMismatch, err := cmd.Flags().GetBool("allow-media-type-mismatch")
+			if err != nil {
+				return fmt.Errorf("error getting allow-media-type-mismatch flag: %w", err)
+			}
 
-	targetDesc, err := remote.Head(repo)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error getting descriptor: %w", err)
-	}
+			compress, err := cmd.Flags().GetBool("compress")
+			if err != nil {
+				return fmt.Errorf("error getting compress flag: %w", err)
+			}
 
-	return referrer{
-		annotations: anns,
-		mediaType:   mediaType,
-		bytes:       b,
-		targetRepo:  repo,
-		targetDesc:  *targetDesc,
-	}, nil
-}
+			ndjson, err := cmd.Flags().GetBool("ndjson")
+			if err != nil {
+				return fmt.Errorf("error getting ndjson flag: %w", err)
+			}
 
-func tryReferrerFromVulnerability(r io.Reader) (referrer, error) {
-	b, err := io.ReadAll(r)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error reading: %w", err)
+			tag, err := cmd.Flags().GetString("tag")
+			if err != nil {
+				return fmt.Errorf("error getting tag: %w", err)
+			}
 
-	}
+			layerTitle, err := cmd.Flags().GetString("layer-title")
+			if err != nil {
+				return fmt.Errorf("error getting layer title: %w", err)
+			}
 
-	var d predicate.CosignVulnPredicate
-	if err := json.Unmarshal(b, &d); err != nil {
-		return referrer{}, fmt.Errorf("failed to unmarshal vulnerability data: %w", errFailedVulnDetection)
-	}
+			manifestStyle, err := cmd.Flags().GetString("manifest-style")
+			if err != nil {
+				return fmt.Errorf("error getting manifest style: %w", err)
+			}
+			switch manifestStyle {
+			case referrer.ManifestStyleArtifact, referrer.ManifestStyleImage:
+			default:
+				return fmt.Errorf("unsupported manifest style: %s", manifestStyle)
+			}
 
-	if len(d.Scanner.Result.Metadata.RepoDigests) == 0 {
-		return referrer{}, fmt.Errorf("no RepoDigests found in vulnerability data: %w", errFailedVulnDetection)
-	}
+			emptyConfig, err := cmd.Flags().GetBool("empty-config")
+			if err != nil {
+				return fmt.Errorf("error getting empty-config flag: %w", err)
+			}
 
-	repo, err := name.NewDigest(d.Scanner.Result.Metadata.RepoDigests[0])
-	if err != nil {
-		return referrer{}, fmt.Errorf("error creating new digest: %w", err)
-	}
+			manifestMediaType, err := cmd.Flags().GetString("image-media-type")
+			if err != nil {
+				return fmt.Errorf("error getting image media type: %w", err)
+			}
+			switch ctypes.MediaType(manifestMediaType) {
+			case "", ctypes.OCIManifestSchema1, ctypes.DockerManifestSchema2:
+			default:
+				return fmt.Errorf("unsupported image media type: %s", manifestMediaType)
+			}
+			if manifestMediaType != "" && manifestStyle == referrer.ManifestStyleImage {
+				return fmt.Errorf("--image-media-type can't be used with --manifest-style image, which is always an OCI image manifest")
+			}
 
-	targetDesc, err := remote.Head(repo)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error fetching target descriptor: %w", err)
-	}
+			kvs, err := cmd.Flags().GetStringArray("annotation")
+			if err != nil {
+				return fmt.Errorf("error getting annotations: %w", err)
+			}
+			flagAnnotations, err := parseAnnotations(kvs)
+			if err != nil {
+				return fmt.Errorf("error parsing annotations: %w", err)
+			}
 
-	log.Logger.Infof("Cosign vulnerability data detected")
+			annotationsFile, err := cmd.Flags().GetString("annotations-file")
+			if err != nil {
+				return fmt.Errorf("error getting annotations file: %w", err)
+			}
 
-	return referrer{
-		annotations: map[string]string{
-			annotationKeyDescription: "Vulnerability Scan Report",
-			annotationKeyCreated:     time.Now().Format(time.RFC3339),
-		},
-		mediaType:  ctypes.MediaType(mediaKeyCosignVuln),
-		bytes:      b,
-		targetRepo: repo,
-		targetDesc: *targetDesc,
-	}, nil
-}
+			envPrefixes, err := cmd.Flags().GetStringArray("annotation-from-env")
+			if err != nil {
+				return fmt.Errorf("error getting annotation-from-env flag: %w", err)
+			}
 
-func referrerFromReader(r io.Reader) (referrer, error) {
-	b, err := io.ReadAll(r)
-	if err != nil {
-		return referrer{}, fmt.Errorf("error reading: %w", err)
-	}
+			// The --annotation flags take precedence over the environment variables, and both over the annotations file.
+			annotations := make(map[string]string)
+			if annotationsFile != "" {
+				annotations, err = loadAnnotationsFile(annotationsFile)
+				if err != nil {
+					return err
+				}
+			}
+			for _, prefix := range envPrefixes {
+				for k, v := range annotationsFromEnv(os.Environ(), prefix) {
+					annotations[k] = v
+				}
+			}
+			for k, v := range flagAnnotations {
+				annotations[k] = v
+			}
+			checkAnnotations(annotations)
 
-	var ref referrer
-	ref, err = tryReferrerFromSBOM(bytes.NewReader(b))
-	if err == nil {
-		return ref, nil
-	} else if err != nil && err != errFailedSBOMDetection {
-		return referrer{}, fmt.Errorf("error processing SBOM: %w", err)
-	}
+			skipHead, err := cmd.Flags().GetBool("skip-head")
+			if err != nil {
+				return fmt.Errorf("error getting skip-head flag: %w", err)
+			}
+			subjectMediaType, err := cmd.Flags().GetString("subject-media-type")
+			if err != nil {
+				return fmt.Errorf("error getting subject-media-type: %w", err)
+			}
+			subjectSize, err := cmd.Flags().GetInt64("subject-size")
+			if err != nil {
+				return fmt.Errorf("error getting subject-size: %w", err)
+			}
+			// The descriptor given in full is used as is, without fetching the subject.
+			if subjectMediaType != "" || cmd.Flags().Changed("subject-size") {
+				if inputOpts.SubjectDigest == "" || subjectMediaType == "" || !cmd.Flags().Changed("subject-size") {
+					return fmt.Errorf("--subject-digest, --subject-media-type and --subject-size must be given together to build the descriptor of the subject")
+				}
+				skipHead = true
+			}
+			if skipHead {
+				if inputOpts.SubjectDigest == "" || subjectMediaType == "" || subjectSize <= 0 {
+					return fmt.Errorf("--subject-digest, --subject-media-type and --subject-size are required with --skip-head to build the descriptor of the subject")
+				}
+				if mt := ctypes.MediaType(subjectMediaType); !mt.IsImage() && !mt.IsIndex() {
+					return fmt.Errorf("unsupported subject media type: %s", subjectMediaType)
+				}
+			}
 
-	log.Logger.Infof("Failed to detect a valid SBOM: ensure the provided SBOM is generated by Trivy, as only Trivy-generated SBOMs are currently supported")
+			platformStr, err := cmd.Flags().GetString("platform")
+			if err != nil {
+				return fmt.Errorf("error getting platform: %w", err)
+			}
+			// "all" attaches the referrer to every platform-specific manifest of the image index.
+			allPlatforms := platformStr == "all"
+			var platform *v1.Platform
+			if platformStr != "" && !allPlatforms {
+				platform, err = v1.ParsePlatform(platformStr)
+				if err != nil {
+					return fmt.Errorf("error parsing platform: %w", err)
+				}
+			}
 
-	ref, err = tryReferrerFromVulnerability(bytes.NewReader(b))
-	if err == nil {
-		return ref, nil
-	} else if err != nil && err != errFailedVulnDetection {
-		return referrer{}, fmt.Errorf("error processing vulnerability: %w", err)
-	}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return fmt.Errorf("error getting output format: %w", err)
+			}
+			if output != "" && output != outputFormatText && output != outputFormatJSON {
+				return fmt.Errorf("unsupported output format: %s", output)
+			}
 
-	log.Logger.Infof("Failed to detect Cosign vulnerability format")
+			fromImage, err := cmd.Flags().GetString("from-image")
+			if err != nil {
+				return fmt.Errorf("error getting from-image flag: %w", err)
+			}
+			if fromImage != "" && (len(paths) > 0 || ndjson) {
+				return fmt.Errorf("--from-image can't be used with the input files or --ndjson")
+			}
 
-	return referrer{}, fmt.Errorf("failed to detect referrer type")
-}
+			outputManifest, err := cmd.Flags().GetString("output-manifest")
+			if err != nil {
+				return fmt.Errorf("error getting output-manifest flag: %w", err)
+			}
+			if outputManifest != "" && (len(paths) > 1 || ndjson) {
+				return fmt.Errorf("--output-manifest can't be used with multiple inputs")
+			}
 
-func putReferrer(r io.Reader) error {
-	ref, err := referrerFromReader(r)
-	if err != nil {
-		return fmt.Errorf("error getting referrer: %w", err)
-	}
+			if allPlatforms && requireImage {
+				return fmt.Errorf("--require-image can't be used with --platform all")
+			}
+			if allPlatforms && (tag != "" || outputManifest != "") {
+				return fmt.Errorf("--tag and --output-manifest can't be used with --platform all")
+			}
+			alsoTo, err := cmd.Flags().GetStringArray("also-to")
+			if err != nil {
+				return fmt.Errorf("error getting also-to: %w", err)
+			}
+			if len(alsoTo) > 0 && (allPlatforms || tag != "" || outputManifest != "") {
+				return fmt.Errorf("--also-to can't be used with --platform all, --tag or --output-manifest")
+			}
+			export, err := cmd.Flags().GetString("export")
+			if err != nil {
+				return fmt.Errorf("error getting export: %w", err)
+			}
+			if export != "" && (len(paths) > 1 || ndjson || allPlatforms) {
+				return fmt.Errorf("--export can't be used with multiple inputs or --platform all")
+			}
+			if export != "" && (dryRun || tag != "" || signKey != "" || replaceExisting || len(alsoTo) > 0) {
+				return fmt.Errorf("--export can't be used with --dry-run, --tag, --sign, --replace-existing-media-type or --also-to, which need the registry")
+			}
 
-	img, err := ref.Image()
-	if err != nil {
-		return fmt.Errorf("error getting image: %w", err)
+			if skipHead && platformStr != "" {
+				return fmt.Errorf("--platform can't be used with --skip-head")
+			}
+
+			inputOpts.SkipHead = skipHead
+			inputOpts.SubjectMediaType = ctypes.MediaType(subjectMediaType)
+			inputOpts.SubjectSize = subjectSize
+			inputOpts.Platform = platform
+			inputOpts.NoTimestamp = noTimestamp
+			inputOpts.TitleFromSBOM = titleFromSBOM
+			inputOpts.RequireImage = requireImage
+			opts := putOptions{
+				Options:                inputOpts,
+				dryRun:                 dryRun,
+				description:            description,
+				note:                   note,
+				noteKey:                noteKey,
+				artifactType:           artifactType,
+				allowMediaTypeMismatch: allowMediaTypeMismatch,
+				compress:               compress,
+				manifestStyle:          manifestStyle,
+				emptyConfig:            emptyConfig,
+				export:                 export,
+				manifestMediaType:      manifestMediaType,
+				layerTitle:             layerTitle,
+				reproducible:           reproducible,
+				tag:                    tag,
+				ndjson:                 ndjson,
+				allPlatforms:           allPlatforms,
+				alsoTo:                 alsoTo,
+				fromImage:              fromImage,
+				signKey:                signKey,
+				concurrency:            concurrency,
+				force:                  force,
+				replaceExisting:        replaceExisting,
+				quiet:                  quiet,
+				annotations:            annotations,
+				output:                 output,
+				outputManifest:         outputManifest,
+			}
+
+			if progress {
+				opts.Progress = progressPrinter(os.Stderr)
+			}
+			if stats {
+				opts.Stats = statsPrinter(os.Stderr)
+			}
+
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			switch {
+			case opts.fromImage != "":
+				err = putReferrerFromImage(ctx, opts.fromImage, os.Stdout, opts)
+			case len(paths) == 0:
+				if opts.ndjson {
+					err = putReferrersNDJSON(ctx, os.Stdin, os.Stdout, opts)
+				} else {
+					err = putReferrer(ctx, os.Stdin, os.Stdout, opts)
+				}
+			case len(paths) == 1:
+				err = putReferrerFromPath(ctx, paths[0], os.Stdout, opts)
+			default:
+				err = putReferrers(ctx, paths, os.Stdout, opts)
+			}
+			err = regOpts.CheckTimeout(err)
+			if err != nil {
+				return fmt.Errorf("error putting referrer: %w", err)
+			}
+
+			return nil
+		},
 	}
+	addInputFlags(putCmd)
+	putCmd.Flags().StringArrayP("file", "f", nil, "file path, HTTP(S) URL or data URI. Can be specified multiple times, or given as arguments. If a file path is not specified, it will accept input from the standard input.")
+	putCmd.Flags().StringArray("also-to", nil, "repository to also attach the referrer to the image of the same digest in, such as a mirror. Can be specified multiple times.")
+	putCmd.Flags().Bool("require-image", false, "fail if the subject is an image index rather than an image, such as when the SBOM is for a single platform")
+	putCmd.Flags().Bool("skip-head", false, "don't fetch the descriptor of the subject, building it from --subject-digest, --subject-media-type and --subject-size instead")
+	putCmd.Flags().String("subject-media-type", "", "media type of the subject manifest, building the descriptor with --subject-digest and --subject-size without fetching it")
+	putCmd.Flags().Int64("subject-size", 0, "size of the subject manifest in bytes, building the descriptor with --subject-digest and --subject-media-type without fetching it")
+	putCmd.Flags().String("platform", "", "platform of the image to attach the referrer to when the subject is an image index (e.g. linux/amd64), or all to attach it to every platform")
+	putCmd.Flags().String("artifact-type", "", "artifact type of the referrer. If not specified, the media type of the detected format is used.")
+	putCmd.Flags().Bool("compress", false, "push the referrer as a gzip-compressed layer")
+	putCmd.Flags().String("from-image", "", "read the SBOM referrer attached to the image as the input instead of a file, such as to move it to another registry")
+	putCmd.Flags().Bool("ndjson", false, "read the input as newline-delimited JSON documents and put each document as a referrer")
+	putCmd.Flags().String("tag", "", "tag to additionally push the referrer with in the repository of the subject, for manual inspection (e.g. sbom-latest)")
+	putCmd.Flags().String("layer-title", "", "title annotation of the layer. If not specified, the file name of the input is used.")
+	putCmd.Flags().String("manifest-style", referrer.ManifestStyleArtifact, "style of the referrer manifest (artifact, image). The image style has the empty config and the artifactType field of OCI 1.1.")
+	putCmd.Flags().Bool("empty-config", false, "store the config of the artifact style manifest as the empty JSON {} instead of the config of an empty image")
+	putCmd.Flags().String("image-media-type", "", fmt.Sprintf("media type of the referrer manifest (%s, %s). If not specified, the media type of the subject is used.", ctypes.OCIManifestSchema1, ctypes.DockerManifestSchema2))
+	putCmd.Flags().Bool("allow-media-type-mismatch", false, "allow an artifact type of another format than the detected one")
+	putCmd.Flags().String("description", "", "description annotation of the referrer. If not specified, a description based on the detected format is used.")
+	putCmd.Flags().String("note", "", "free-text note left on the referrer as an annotation")
+	putCmd.Flags().String("note-key", defaultNoteKey, "annotation key of the note given with --note")
+	putCmd.Flags().StringArray("annotation", nil, "annotation of the referrer in the key=value format. Can be specified multiple times.")
+	putCmd.Flags().StringArray("annotation-from-env", nil, "copy the environment variables starting with the prefix to the annotations, such as CI_ for CI_COMMIT_SHA as ci.commit.sha. Can be specified multiple times.")
+	putCmd.Flags().String("annotations-file", "", "JSON or YAML file of the annotations of the referrer. The --annotation flags take precedence.")
+	putCmd.Flags().StringP("output", "o", "", "print the pushed referrer in the given format (text, json)")
+	putCmd.Flags().String("output-manifest", "", "file path to save the manifest of the pushed referrer to")
+	putCmd.Flags().String("export", "", "write the referrer to the tarball of an OCI image layout instead of pushing it, to push it later with the push command")
+	putCmd.Flags().Int("concurrency", defaultConcurrency, "number of referrers to put concurrently when multiple files are given")
+	putCmd.Flags().Bool("no-timestamp", false, "don't set the created timestamp annotation, for reproducible referrers")
+	putCmd.Flags().Bool("reproducible", false, "build the identical referrer from the same input on every run, omitting the timestamp and the file name of the input")
+	putCmd.Flags().Bool("annotation-title-from-sbom", false, "set the org.opencontainers.image.title annotation of the referrer to the name of the SBOM (the metadata component of CycloneDX, the document name of SPDX)")
+	putCmd.Flags().Bool("force", false, "push the referrer even if an identical referrer is already attached to the image")
+	putCmd.Flags().Bool("replace-existing-media-type", false, "delete the other referrers of the subject with the same artifact type after pushing, keeping exactly one of each type")
+	putCmd.Flags().Bool("dry-run", false, "build the referrer and print its manifest without pushing it")
+	putCmd.Flags().Bool("progress", false, "print the upload progress to the standard error")
+	putCmd.Flags().Bool("stats", false, "print the format, the package count and the root purl of the SBOM to the standard error")
+	putCmd.Flags().Bool("sign", false, "sign the pushed referrer with cosign")
+	putCmd.Flags().String("sign-key", "", "key file or KMS URI to sign the referrer with (e.g. cosign.key, awskms:///alias/key)")
+	addRegistryFlags(putCmd)
+
+	getCmd := &cobra.Command{
+		Use:   "get IMAGE",
+		Short: "get a referrer from the oci registry",
+		Example: `  trivy referrer get YOUR_IMAGE
+  # Get the CycloneDX SBOM and save it to a file
+  trivy referrer get --media-type application/vnd.cyclonedx+json -o sbom.json YOUR_IMAGE`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mediaType, err := cmd.Flags().GetString("media-type")
+			if err != nil {
+				return fmt.Errorf("error getting media type: %w", err)
+			}
 
-	tag, err := ref.Tag(img)
-	if err != nil {
-		return fmt.Errorf("error getting tag: %w", err)
+			path, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return fmt.Errorf("error getting output path: %w", err)
+			}
+
+			regOpts, err := registryOptionsFromFlags(cmd)
+			if err != nil {
+				return fmt.Errorf("error getting registry options: %w", err)
+			}
+
+			var writer io.Writer
+			if path != "" {
+				fp, err := os.Create(path)
+				if err != nil {
+					return fmt.Errorf("error creating file: %w", err)
+				}
+				defer fp.Close()
+
+				writer = fp
+			} else {
+				writer = os.Stdout
+			}
+
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			err = regOpts.CheckTimeout(getReferrer(ctx, args[0], mediaType, writer, regOpts))
+			if err != nil {
+				return fmt.Errorf("error getting referrer: %w", err)
+			}
+
+			return nil
+		},
 	}
+	getCmd.Flags().String("media-type", "", "media type of the referrer to get. If not specified, the first referrer found is used.")
+	getCmd.Flags().StringP("output", "o", "", "output file path. If an output path is not specified, it will write to the standard output.")
+	addRegistryFlags(getCmd)
+
+	listCmd := &cobra.Command{
+		Use:   "list IMAGE",
+		Short: "list referrers attached to the image",
+		Example: `  trivy referrer list YOUR_IMAGE
+  # Print the referrers index as JSON
+  trivy referrer list -o json YOUR_IMAGE`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := cmd.Flags().GetString("output")
+			if err != nil {
+				return fmt.Errorf("error getting output format: %w", err)
+			}
 
-	log.Logger.Infof("Pushing referrer to %s", tag.String())
+			regOpts, err := registryOptionsFromFlags(cmd)
+			if err != nil {
+				return fmt.Errorf("error getting registry options: %w", err)
+			}
 
-	err = remote.Write(tag, img, remote.WithAuthFromKeychain(authn.DefaultKeychain))
-	if err != nil {
-		return fmt.Errorf("error pushing referrer: %w", err)
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			err = regOpts.CheckTimeout(listReferrers(ctx, args[0], format, os.Stdout, regOpts))
+			if err != nil {
+				return fmt.Errorf("error listing referrers: %w", err)
+			}
+
+			return nil
+		},
 	}
+	listCmd.Flags().StringP("output", "o", listFormatTable, "output format (table, json)")
+	addRegistryFlags(listCmd)
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete IMAGE",
+		Short: "delete referrers attached to the image",
+		Example: `  # Delete the CycloneDX SBOM
+  trivy referrer delete --media-type application/vnd.cyclonedx+json YOUR_IMAGE
+  # Delete all referrers
+  trivy referrer delete --all YOUR_IMAGE`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mediaType, err := cmd.Flags().GetString("media-type")
+			if err != nil {
+				return fmt.Errorf("error getting media type: %w", err)
+			}
 
-	return nil
-}
+			digest, err := cmd.Flags().GetString("digest")
+			if err != nil {
+				return fmt.Errorf("error getting digest: %w", err)
+			}
 
-func main() {
-	rootCmd := &cobra.Command{
-		Short: "A Trivy plugin for oci referrers",
-		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-			debug, err := cmd.Flags().GetBool("debug")
+			all, err := cmd.Flags().GetBool("all")
 			if err != nil {
-				return fmt.Errorf("error getting debug flag: %w", err)
+				return fmt.Errorf("error getting all flag: %w", err)
 			}
 
-			quiet, err := cmd.Flags().GetBool("quiet")
+			regOpts, err := registryOptionsFromFlags(cmd)
 			if err != nil {
-				return fmt.Errorf("error getting quiet flag: %w", err)
+				return fmt.Errorf("error getting registry options: %w", err)
 			}
 
-			if err := log.InitLogger(debug, quiet); err != nil {
-				return err
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			opts := deleteOptions{
+				RegistryOptions: regOpts,
+				mediaType:       mediaType,
+				digest:          digest,
+				all:             all,
+			}
+			err = regOpts.CheckTimeout(deleteReferrers(ctx, args[0], opts))
+			if err != nil {
+				return fmt.Errorf("error deleting referrers: %w", err)
 			}
 
 			return nil
 		},
 	}
-	rootCmd.PersistentFlags().BoolP("debug", "d", false, "debug mode")
-	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "suppress log output")
-
-	putCmd := &cobra.Command{
-		Use:   "put",
-		Short: "put a referrer to the oci registry",
-		Example: `  trivy image -q -f cyclonedx YOUR_IMAGE | trivy referrer put
-  # Put SBOM attestation
-  trivy referrer put -f sbom.json`,
+	deleteCmd.Flags().String("media-type", "", "media type of the referrers to delete")
+	deleteCmd.Flags().String("digest", "", "digest of the referrer to delete")
+	deleteCmd.Flags().Bool("all", false, "delete all matching referrers. Without filters, every referrer of the image is deleted.")
+	addRegistryFlags(deleteCmd)
+
+	validateCmd := &cobra.Command{
+		Use:   "validate [FILE]",
+		Short: "validate that the input can be put as a referrer, without pushing it",
+		Example: `  trivy image -q -f cyclonedx YOUR_IMAGE | trivy referrer validate
+  trivy referrer validate sbom.json`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			path, err := cmd.Flags().GetString("file")
+			if err := checkPasswordStdin(cmd, args); err != nil {
+				return err
+			}
+
+			opts, err := inputOptionsFromFlags(cmd)
 			if err != nil {
-				return fmt.Errorf("error getting file path: %w", err)
+				return err
 			}
+			regOpts := opts.RegistryOptions
 
-			var reader io.Reader
-			if path != "" {
-				fp, err := os.Open(path)
+			platformStr, err := cmd.Flags().GetString("platform")
+			if err != nil {
+				return fmt.Errorf("error getting platform: %w", err)
+			}
+			if platformStr != "" {
+				opts.Platform, err = v1.ParsePlatform(platformStr)
 				if err != nil {
-					return fmt.Errorf("error opening file: %w", err)
+					return fmt.Errorf("error parsing platform: %w", err)
 				}
-				defer fp.Close()
+			}
 
-				reader = fp
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			if len(args) == 0 {
+				err = validateReferrer(ctx, os.Stdin, os.Stdout, opts)
 			} else {
-				reader = os.Stdin
+				err = validateReferrerFromPath(ctx, args[0], os.Stdout, opts)
+			}
+			if err = regOpts.CheckTimeout(err); err != nil {
+				return fmt.Errorf("error validating referrer: %w", err)
 			}
 
-			err = putReferrer(reader)
+			return nil
+		},
+	}
+	addInputFlags(validateCmd)
+	validateCmd.Flags().String("platform", "", "platform of the image to attach the referrer to when the subject is an image index (e.g. linux/amd64)")
+	addRegistryFlags(validateCmd)
+
+	copyCmd := &cobra.Command{
+		Use:   "copy SOURCE_IMAGE DESTINATION_IMAGE",
+		Short: "copy referrers attached to the image to another image",
+		Example: `  # Copy the referrers to the mirrored image
+  trivy referrer copy ghcr.io/org/app:v1 mirror.example.com/app:v1`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			regOpts, err := registryOptionsFromFlags(cmd)
 			if err != nil {
-				return fmt.Errorf("error putting referrer: %w", err)
+				return fmt.Errorf("error getting registry options: %w", err)
+			}
+
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			opts := referrer.Options{
+				RegistryOptions: regOpts,
+				Subject:         args[1],
+			}
+			err = regOpts.CheckTimeout(copyReferrers(ctx, args[0], opts))
+			if err != nil {
+				return fmt.Errorf("error copying referrers: %w", err)
 			}
 
 			return nil
 		},
 	}
-	putCmd.Flags().StringP("file", "f", "", "file path. If a file path is not specified, it will accept input from the standard input.")
+	addRegistryFlags(copyCmd)
+
+	buildCmd := &cobra.Command{
+		Use:   "build [FILE...]",
+		Short: "build the referrer into the tarball of an oci image layout to push it later with the push command",
+		Example: `  # Build the referrer, then push it in another step
+  trivy referrer build -f sbom.cdx.json --export referrer.tar
+  trivy referrer push referrer.tar`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			export, err := cmd.Flags().GetString("export")
+			if err != nil {
+				return fmt.Errorf("error getting export: %w", err)
+			}
+			if export == "" {
+				return fmt.Errorf("--export is required")
+			}
 
-	rootCmd.AddCommand(putCmd)
+			// build is put --export, which doesn't access the registry except for resolving the subject.
+			return putCmd.RunE(cmd, args)
+		},
+	}
+	buildCmd.Flags().AddFlagSet(putCmd.Flags())
+
+	pushCmd := &cobra.Command{
+		Use:   "push LAYOUT",
+		Short: "push the referrers exported with build or put --export to the oci registry",
+		Example: `  # Build the referrer, then push it in another step
+  trivy referrer build -f sbom.cdx.json --export referrer.tar
+  trivy referrer push referrer.tar`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			quiet, err := cmd.Flags().GetBool("quiet")
+			if err != nil {
+				return fmt.Errorf("error getting quiet flag: %w", err)
+			}
+
+			regOpts, err := registryOptionsFromFlags(cmd)
+			if err != nil {
+				return fmt.Errorf("error getting registry options: %w", err)
+			}
+
+			ctx, cancel := regOpts.WithTimeout(cmd.Context())
+			defer cancel()
+
+			opts := referrer.Options{
+				RegistryOptions: regOpts,
+			}
+			err = regOpts.CheckTimeout(pushLayout(ctx, args[0], os.Stdout, quiet, opts))
+			if err != nil {
+				return fmt.Errorf("error pushing layout: %w", err)
+			}
+
+			return nil
+		},
+	}
+	addRegistryFlags(pushCmd)
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "print the version and the build information",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printVersion(os.Stdout)
+		},
+	}
+	rootCmd.SetVersionTemplate(fmt.Sprintf("Version: {{.Version}}\nCommit: %s\n", buildCommit()))
 
-	if err := putCmd.Execute(); err != nil {
-		log.Logger.Fatal(err)
+	rootCmd.AddCommand(putCmd)
+	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(pushCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(versionCmd)
+
+	// Cancel the in-flight registry requests on Ctrl-C or termination.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		log.Logger.Error(err)
+		os.Exit(exitCode(err))
 	}
 }
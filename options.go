@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// checkPasswordStdin returns an error if the password is read from the standard input, which is also the input without files.
+func checkPasswordStdin(cmd *cobra.Command, paths []string) error {
+	passwordStdin, err := cmd.Flags().GetBool("password-stdin")
+	if err != nil {
+		return fmt.Errorf("error getting password-stdin flag: %w", err)
+	}
+	if passwordStdin && len(paths) == 0 {
+		return fmt.Errorf("an input file is required when --password-stdin is given, which reads the password from the standard input")
+	}
+	return nil
+}
+
+// addInputFlags adds the flags of how the input is read and attached to the subject, shared by put and validate.
+func addInputFlags(cmd *cobra.Command) {
+	cmd.Flags().String("subject", "", "image reference to attach the referrer to. A tag is resolved to its current digest. If not specified, the image described in the input is used.")
+	cmd.Flags().String("repository", "", "repository to attach the referrer to, keeping the digest described in the input (e.g. mirror.example.com/app)")
+	cmd.Flags().String("subject-digest", "", "digest of the image to attach the referrer to with --repository, ignoring the subject described in the input (e.g. sha256:...)")
+	cmd.Flags().String("type", "", "type of the input (sbom, vulnerability, attestation, vex). If not specified, it is detected from the input.")
+	cmd.Flags().Int64("max-layer-size", referrer.DefaultMaxSize, "maximum size of the input in bytes after decompression. 0 means no limit.")
+	cmd.Flags().Bool("allow-chaining", false, "allow attaching the referrer to a subject which is itself a referrer")
+	cmd.Flags().Bool("keep-attestation", false, "put an SBOM attestation as is instead of the SBOM in its predicate")
+	cmd.Flags().Bool("allow-any-subject", false, "allow attaching the referrer to any manifest, such as an artifact pushed with ORAS, not only to images")
+}
+
+// inputOptionsFromFlags returns the options of the flags added with addInputFlags and addRegistryFlags,
+// so that validate builds the referrer in the same way as put.
+func inputOptionsFromFlags(cmd *cobra.Command) (referrer.Options, error) {
+	regOpts, err := registryOptionsFromFlags(cmd)
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting registry options: %w", err)
+	}
+
+	inputType, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting type: %w", err)
+	}
+	switch inputType {
+	case "", referrer.TypeSBOM, referrer.TypeVulnerability, referrer.TypeAttestation, referrer.TypeVEX:
+	default:
+		return referrer.Options{}, fmt.Errorf("unsupported type: %s", inputType)
+	}
+
+	allowChaining, err := cmd.Flags().GetBool("allow-chaining")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting allow-chaining flag: %w", err)
+	}
+
+	allowAnySubject, err := cmd.Flags().GetBool("allow-any-subject")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting allow-any-subject flag: %w", err)
+	}
+
+	keepAttestation, err := cmd.Flags().GetBool("keep-attestation")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting keep-attestation flag: %w", err)
+	}
+
+	maxSize, err := cmd.Flags().GetInt64("max-layer-size")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting max-layer-size: %w", err)
+	}
+	if maxSize < 0 {
+		return referrer.Options{}, fmt.Errorf("--max-layer-size must not be negative")
+	}
+
+	subject, err := cmd.Flags().GetString("subject")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting subject: %w", err)
+	}
+
+	repository, err := cmd.Flags().GetString("repository")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting repository: %w", err)
+	}
+	if subject != "" && repository != "" {
+		return referrer.Options{}, fmt.Errorf("--subject and --repository are mutually exclusive")
+	}
+
+	subjectDigest, err := cmd.Flags().GetString("subject-digest")
+	if err != nil {
+		return referrer.Options{}, fmt.Errorf("error getting subject digest: %w", err)
+	}
+	if subjectDigest != "" && repository == "" {
+		return referrer.Options{}, fmt.Errorf("--repository is required with --subject-digest")
+	}
+
+	return referrer.Options{
+		RegistryOptions: regOpts,
+		Subject:         subject,
+		Repository:      repository,
+		SubjectDigest:   subjectDigest,
+		Type:            inputType,
+		AllowChaining:   allowChaining,
+		AllowAnySubject: allowAnySubject,
+		KeepAttestation: keepAttestation,
+		MaxSize:         maxSize,
+	}, nil
+}
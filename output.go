@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+// putResult describes the pushed referrer.
+// It is printed as a JSON object per line with `--output json`, and the fields are kept stable.
+type putResult struct {
+	// Reference is the digest reference of the pushed referrer, e.g. "ghcr.io/org/app@sha256:...".
+	Reference string `json:"reference"`
+	// Subject is the digest reference of the image the referrer is attached to.
+	Subject     string            `json:"subject"`
+	MediaType   string            `json:"mediaType"`
+	Annotations map[string]string `json:"annotations"`
+	// Skipped is true if the push was skipped, since the same referrer is already present at Reference.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+func writePutResult(w io.Writer, result putResult, format string) error {
+	switch format {
+	case outputFormatText:
+		fmt.Fprintf(w, "Reference: %s\n", result.Reference)
+		fmt.Fprintf(w, "Subject: %s\n", result.Subject)
+		fmt.Fprintf(w, "Media Type: %s\n", result.MediaType)
+		if result.Skipped {
+			fmt.Fprintln(w, "Skipped: already present")
+		}
+		fmt.Fprintln(w, "Annotations:")
+		keys := make([]string, 0, len(result.Annotations))
+		for k := range result.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", k, result.Annotations[k])
+		}
+		return nil
+	case outputFormatJSON:
+		return json.NewEncoder(w).Encode(result)
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
@@ -0,0 +1,168 @@
+package referrer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// ref. https://github.com/in-toto/attestation/blob/main/spec/v1/envelope.md
+	MediaKeyInToto = "application/vnd.in-toto+json"
+
+	// AnnotationKeyPredicateType is set to the predicate type of the attestation.
+	AnnotationKeyPredicateType = "in-toto.io/predicate-type"
+
+	inTotoStatementTypePrefix = "https://in-toto.io/Statement/"
+)
+
+var errFailedAttestationDetection = fmt.Errorf("failed to detect in-toto attestation")
+
+type inTotoStatement struct {
+	Type          string `json:"_type"`
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Name   string            `json:"name"`
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+	Predicate json.RawMessage `json:"predicate"`
+}
+
+// sbomPredicateTypePrefixes are the prefixes of the predicate types of the SBOM attestations, such as those of cosign.
+var sbomPredicateTypePrefixes = []string{
+	"https://cyclonedx.org/",
+	"https://spdx.dev/Document",
+}
+
+// sbomPredicate returns the SBOM in the predicate of the statement if it is an SBOM attestation.
+// The SPDX tag-value document is embedded as a JSON string.
+func sbomPredicate(st inTotoStatement) ([]byte, bool) {
+	var isSBOM bool
+	for _, prefix := range sbomPredicateTypePrefixes {
+		if strings.HasPrefix(st.PredicateType, prefix) {
+			isSBOM = true
+		}
+	}
+	if !isSBOM || len(st.Predicate) == 0 {
+		return nil, false
+	}
+
+	var s string
+	if err := json.Unmarshal(st.Predicate, &s); err == nil {
+		return []byte(s), true
+	}
+	return st.Predicate, true
+}
+
+// dsseEnvelope is the envelope in which a signed statement is wrapped.
+// ref. https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+}
+
+// decodeStatement decodes the in-toto statement given as is or wrapped in a DSSE envelope,
+// whose payload is already decoded in the probe.
+func decodeStatement(b []byte, p inputProbe) (inTotoStatement, error) {
+	if !p.isAttestation() {
+		return inTotoStatement{}, errFailedAttestationDetection
+	}
+	if p.PayloadType == MediaKeyInToto {
+		payload, err := base64.StdEncoding.DecodeString(p.Payload)
+		if err != nil {
+			return inTotoStatement{}, fmt.Errorf("error decoding DSSE payload: %w", err)
+		}
+		b = payload
+	}
+
+	var st inTotoStatement
+	if err := json.Unmarshal(b, &st); err != nil {
+		return inTotoStatement{}, errFailedAttestationDetection
+	}
+	if !strings.HasPrefix(st.Type, inTotoStatementTypePrefix) {
+		return inTotoStatement{}, errFailedAttestationDetection
+	}
+
+	return st, nil
+}
+
+func repoFromStatement(st inTotoStatement, opts ...name.Option) (name.Digest, error) {
+	for _, s := range st.Subject {
+		if d, ok := s.Digest["sha256"]; ok {
+			digest, err := name.NewDigest(fmt.Sprintf("%s@sha256:%s", s.Name, d), opts...)
+			if err != nil {
+				return name.Digest{}, fmt.Errorf("error creating new digest: %w", err)
+			}
+			return digest, nil
+		}
+	}
+
+	return name.Digest{}, fmt.Errorf("no subject with a sha256 digest found in the statement")
+}
+
+func tryReferrerFromAttestation(ctx context.Context, b []byte, p inputProbe, opts Options) (*Referrer, error) {
+	st, err := decodeStatement(b, p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SBOM is unwrapped from the attestation so that the consumers of SBOM referrers find it, unless it is kept.
+	if predicate, ok := sbomPredicate(st); ok && !opts.KeepAttestation {
+		log.Logger.Infof("SBOM attestation detected: %s, putting the SBOM in its predicate", st.PredicateType)
+		ref, err := tryReferrerFromSBOM(ctx, predicate, probeInput(predicate), opts)
+		if err == nil {
+			return ref, nil
+		} else if !errors.Is(err, errFailedSBOMDetection) {
+			return nil, fmt.Errorf("error processing SBOM in the attestation: %w", err)
+		}
+		log.Logger.Infof("Failed to detect the SBOM in the attestation, putting the attestation as is")
+	}
+
+	log.Logger.Infof("in-toto attestation detected: %s", st.PredicateType)
+
+	var repo name.Digest
+	if opts.hasSubject() {
+		repo, err = opts.subjectRepo(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		repo, err = repoFromStatement(st, opts.NameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("error getting repository from attestation: %w", err)
+		}
+	}
+	if !opts.hasSubject() && opts.Repository != "" {
+		repo, err = opts.withRepository(repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo, targetDesc, err := subjectDescriptor(ctx, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subject descriptor: %w", err)
+	}
+
+	anns := map[string]string{
+		AnnotationKeyDescription: "in-toto Attestation",
+	}
+	if st.PredicateType != "" {
+		anns[AnnotationKeyPredicateType] = st.PredicateType
+	}
+
+	return &Referrer{
+		Annotations: anns,
+		MediaType:   ctypes.MediaType(MediaKeyInToto),
+		Bytes:       b,
+		TargetRepo:  repo,
+		TargetDesc:  targetDesc,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package referrer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// The registries of GitHub and GitLab, whose CI jobs have a token for them in the environment.
+const (
+	ghcrRegistry   = "ghcr.io"
+	gitlabRegistry = "registry.gitlab.com"
+)
+
+// ciHelper resolves the credentials for GitHub Container Registry from GITHUB_TOKEN,
+// and for the GitLab container registry from CI_JOB_TOKEN, as the basic credentials
+// which the registries exchange for a bearer token. Other registries are left to the next keychain.
+// ref. https://docs.github.com/en/packages/working-with-a-github-packages-registry/working-with-the-container-registry#authenticating-to-the-container-registry
+// ref. https://docs.gitlab.com/ee/user/packages/container_registry/authenticate_with_container_registry.html
+type ciHelper struct{}
+
+func (ciHelper) Get(serverURL string) (string, string, error) {
+	switch {
+	case serverURL == ghcrRegistry && os.Getenv("GITHUB_TOKEN") != "":
+		// GHCR accepts any username with the token.
+		username := os.Getenv("GITHUB_ACTOR")
+		if username == "" {
+			username = "token"
+		}
+		return username, os.Getenv("GITHUB_TOKEN"), nil
+	case isGitLabRegistry(serverURL) && os.Getenv("CI_JOB_TOKEN") != "":
+		return "gitlab-ci-token", os.Getenv("CI_JOB_TOKEN"), nil
+	}
+
+	return "", "", fmt.Errorf("no CI token for %s", serverURL)
+}
+
+// isGitLabRegistry reports whether the host is registry.gitlab.com,
+// or the registry of the self-managed GitLab given in CI_REGISTRY of the job.
+func isGitLabRegistry(host string) bool {
+	return host == gitlabRegistry || (host != "" && host == os.Getenv("CI_REGISTRY"))
+}
+
+// ciKeychain falls back to anonymous when no CI token is available for the registry,
+// so that a multi keychain moves on to the next keychain.
+var ciKeychain = authn.NewKeychainFromHelper(ciHelper{})
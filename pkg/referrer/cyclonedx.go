@@ -0,0 +1,55 @@
+package referrer
+
+import (
+	"fmt"
+	"strings"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// componentPurl returns the purl identifying the component.
+// Trivy sets the purl to bom-ref, and the purl field is used if bom-ref isn't a purl.
+func componentPurl(c cdx.Component) string {
+	if strings.HasPrefix(c.BOMRef, "pkg:") {
+		return c.BOMRef
+	}
+	if strings.HasPrefix(c.PackageURL, "pkg:") {
+		return c.PackageURL
+	}
+	return ""
+}
+
+// repoFromCycloneDX returns the image described by the metadata component of the BOM.
+// It fails if the image is missing or ambiguous rather than attaching the SBOM to a wrong image.
+func repoFromCycloneDX(bom *cdx.BOM, opts ...name.Option) (name.Reference, error) {
+	if bom.Metadata == nil || bom.Metadata.Component == nil {
+		return nil, fmt.Errorf("the SBOM has no metadata component describing the image; use --subject to specify the image")
+	}
+
+	component := *bom.Metadata.Component
+	p := componentPurl(component)
+	if p == "" {
+		return nil, fmt.Errorf("the metadata component %q is not identified by a purl; use --subject to specify the image", component.BOMRef)
+	}
+
+	repo, err := repoFromPurl(p, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Other container images at the top level make the subject ambiguous.
+	if bom.Components != nil {
+		for _, c := range *bom.Components {
+			if c.Type != cdx.ComponentTypeContainer {
+				continue
+			}
+			other, err := repoFromPurl(componentPurl(c), opts...)
+			if err == nil && other.String() != repo.String() {
+				return nil, fmt.Errorf("the SBOM describes multiple images (%s and %s); use --subject to specify the image", repo.String(), other.String())
+			}
+		}
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,64 @@
+package referrer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/trivy/pkg/sbom"
+)
+
+func TestRepoFromCycloneDX(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		wantErr string
+	}{
+		{
+			name:    "purl in bom-ref",
+			fixture: "cyclonedx.json",
+		},
+		{
+			name:    "bom-ref not a purl, with the purl field",
+			fixture: "cyclonedx-bomref.json",
+		},
+		{
+			name:    "bom-ref not a purl, without the purl field",
+			fixture: "cyclonedx-nopurl.json",
+			wantErr: "is not identified by a purl",
+		},
+		{
+			name:    "no metadata component",
+			fixture: "cyclonedx-nometa.json",
+			wantErr: "has no metadata component",
+		},
+		{
+			name:    "multiple images",
+			fixture: "cyclonedx-multi.json",
+			wantErr: "describes multiple images",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := testSubject(t)
+			doc, err := decodeSBOM(loadFixture(t, tt.fixture, subject), sbom.FormatCycloneDXJSON)
+			if err != nil {
+				t.Fatalf("decodeSBOM() error = %s", err)
+			}
+
+			repo, err := repoFromCycloneDX(doc.bom)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("repoFromCycloneDX() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("repoFromCycloneDX() error = %s", err)
+			}
+			if repo.String() != subject.String() {
+				t.Errorf("repoFromCycloneDX() = %s, want %s", repo, subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package referrer
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sync"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// ecrHelperBinary is the Amazon ECR credential helper, which mints tokens from the AWS credentials chain.
+// ref. https://github.com/awslabs/amazon-ecr-credential-helper
+const ecrHelperBinary = "docker-credential-ecr-login"
+
+// ref. https://docs.aws.amazon.com/AmazonECR/latest/userguide/Registries.html
+var ecrHostPattern = regexp.MustCompile(`^[0-9]{12}\.dkr\.ecr(-fips)?\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+func isECR(host string) bool {
+	return ecrHostPattern.MatchString(host)
+}
+
+// ecrHelper limits the inner helper to Amazon ECR, so that the other registries are left to the next keychain.
+// The inner helper has the interface of ecr.NewECRHelper() of github.com/awslabs/amazon-ecr-credential-helper/ecr-login.
+type ecrHelper struct {
+	inner authn.Helper
+}
+
+func (h ecrHelper) Get(serverURL string) (string, string, error) {
+	if !isECR(serverURL) {
+		return "", "", fmt.Errorf("%s is not an ECR registry", serverURL)
+	}
+	return h.inner.Get(serverURL)
+}
+
+// ecrLoginBinary resolves the credentials with the ECR credential helper installed in PATH.
+type ecrLoginBinary struct{}
+
+func (ecrLoginBinary) Get(serverURL string) (string, string, error) {
+	if _, err := exec.LookPath(ecrHelperBinary); err != nil {
+		warnECRHelperMissing.Do(func() {
+			log.Logger.Warnf("%s is an ECR registry, but %s is not found in PATH: install it to resolve the credentials from the AWS credentials chain", serverURL, ecrHelperBinary)
+		})
+		return "", "", fmt.Errorf("error finding %s: %w", ecrHelperBinary, err)
+	}
+
+	return newCredentialHelper(ecrHelperBinary).Get(serverURL)
+}
+
+// warnECRHelperMissing warns once, since the credentials are resolved for every registry operation.
+var warnECRHelperMissing sync.Once
+
+// ecrKeychain falls back to anonymous for non-ECR registries and when the helper is unavailable,
+// so that a multi keychain moves on to the next keychain.
+var ecrKeychain = authn.NewKeychainFromHelper(ecrHelper{inner: ecrLoginBinary{}})
@@ -0,0 +1,40 @@
+package referrer
+
+import "testing"
+
+// fakeHelper returns the fixed credentials and records the requested servers.
+type fakeHelper struct {
+	servers *[]string
+}
+
+func (h fakeHelper) Get(serverURL string) (string, string, error) {
+	*h.servers = append(*h.servers, serverURL)
+	return "AWS", "token", nil
+}
+
+func TestECRHelper(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		wantErr bool
+	}{
+		{name: "ECR", server: "123456789012.dkr.ecr.us-east-1.amazonaws.com"},
+		{name: "ECR FIPS", server: "123456789012.dkr.ecr-fips.us-gov-west-1.amazonaws.com"},
+		{name: "ECR China", server: "123456789012.dkr.ecr.cn-north-1.amazonaws.com.cn"},
+		{name: "other registry", server: "ghcr.io", wantErr: true},
+		{name: "ECR Public", server: "public.ecr.aws", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []string
+			_, _, err := ecrHelper{inner: fakeHelper{servers: &servers}}.Get(tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %t", err, tt.wantErr)
+			}
+			// The other registries must not reach the ECR credential helper.
+			if want := !tt.wantErr; (len(servers) == 1) != want {
+				t.Errorf("got inner helper called for %v, want called %t", servers, want)
+			}
+		})
+	}
+}
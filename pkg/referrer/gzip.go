@@ -0,0 +1,46 @@
+package referrer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns the decompressed input if it is gzip-compressed, or the input as-is otherwise.
+// The input is detected by the magic bytes so that both files and the standard input are supported.
+// The decompressed input is limited to max bytes as well, unless max is zero.
+func decompress(b []byte, max int64) ([]byte, error) {
+	if !bytes.HasPrefix(b, gzipMagic) {
+		return b, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("error opening gzip: %w", err)
+	}
+	defer zr.Close()
+
+	decompressed, err := readLimited(zr, max)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing gzip: %w", err)
+	}
+
+	return decompressed, nil
+}
+
+// compress returns the gzip-compressed input.
+// The gzip header has no name nor time so that the same input results in the same bytes.
+func compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, fmt.Errorf("error compressing gzip: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing gzip: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
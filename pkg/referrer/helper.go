@@ -0,0 +1,57 @@
+package referrer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+const credentialHelperPrefix = "docker-credential-"
+
+// credentialHelper resolves the credentials by running the credential helper binary
+// with the docker credential helper protocol.
+// ref. https://github.com/docker/docker-credential-helpers#development
+type credentialHelper struct {
+	binary string
+}
+
+// newCredentialHelper returns the credential helper with the given name.
+// Both the full binary name (docker-credential-ecr-login) and the suffix (ecr-login) are accepted, as in credHelpers of the docker config.
+func newCredentialHelper(name string) credentialHelper {
+	if !strings.HasPrefix(name, credentialHelperPrefix) {
+		name = credentialHelperPrefix + name
+	}
+	return credentialHelper{binary: name}
+}
+
+func (h credentialHelper) Get(serverURL string) (string, string, error) {
+	cmd := exec.Command(h.binary, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		log.Logger.Debugf("Failed to get credentials for %s with %s: %s: %s", serverURL, h.binary, err, stderr.String())
+		return "", "", fmt.Errorf("error running %s: %w", h.binary, err)
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return "", "", fmt.Errorf("error decoding credentials from %s: %w", h.binary, err)
+	}
+
+	return creds.Username, creds.Secret, nil
+}
+
+// helperKeychain falls back to anonymous when the helper fails, so that a multi keychain moves on to the next keychain.
+func helperKeychain(name string) authn.Keychain {
+	return authn.NewKeychainFromHelper(newCredentialHelper(name))
+}
@@ -0,0 +1,170 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+const (
+	// ManifestStyleArtifact sets the artifact type to the config media type of the manifest.
+	ManifestStyleArtifact = "artifact"
+	// ManifestStyleImage sets the artifact type to the artifactType field of the manifest with the empty config,
+	// as recommended by the OCI image spec 1.1.
+	// ref. https://github.com/opencontainers/image-spec/blob/main/manifest.md#guidelines-for-artifact-usage
+	ManifestStyleImage = "image"
+
+	// MediaKeyEmpty is the media type of the empty config of ManifestStyleImage.
+	MediaKeyEmpty = "application/vnd.oci.empty.v1+json"
+)
+
+var emptyConfig = []byte("{}")
+
+// IsEmptyConfig reports whether the config descriptor is of the empty config `{}`, whatever its media type,
+// such as the one of the artifact style manifest pushed with EmptyConfig.
+func IsEmptyConfig(desc v1.Descriptor) bool {
+	h, _, err := v1.SHA256(bytes.NewReader(emptyConfig))
+	return err == nil && desc.Size == int64(len(emptyConfig)) && desc.Digest == h
+}
+
+// imageManifest is the OCI image manifest with the artifactType field, which v1.Manifest doesn't have.
+type imageManifest struct {
+	SchemaVersion int64             `json:"schemaVersion"`
+	MediaType     ctypes.MediaType  `json:"mediaType"`
+	ArtifactType  ctypes.MediaType  `json:"artifactType,omitempty"`
+	Config        v1.Descriptor     `json:"config"`
+	Layers        []v1.Descriptor   `json:"layers"`
+	Subject       *v1.Descriptor    `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// rawImage is the image with the given manifest, consisting of the empty config and the layer.
+type rawImage struct {
+	manifest  []byte
+	mediaType ctypes.MediaType
+	layer     v1.Layer
+}
+
+func (i *rawImage) RawConfigFile() ([]byte, error) {
+	return emptyConfig, nil
+}
+
+func (i *rawImage) MediaType() (ctypes.MediaType, error) {
+	return i.mediaType, nil
+}
+
+func (i *rawImage) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i *rawImage) LayerByDigest(h v1.Hash) (partial.CompressedLayer, error) {
+	if digest, err := i.layer.Digest(); err == nil && digest == h {
+		return i.layer, nil
+	}
+
+	config := static.NewLayer(emptyConfig, MediaKeyEmpty)
+	if digest, err := config.Digest(); err == nil && digest == h {
+		return config, nil
+	}
+
+	return nil, fmt.Errorf("blob %s not found", h)
+}
+
+// emptyConfigImage returns the image of the manifest with the empty config `{}` of the config media type.
+// The artifactType field is omitted if artifactType is empty.
+func (r *Referrer) emptyConfigImage(layer v1.Layer, mediaType, configMediaType, artifactType ctypes.MediaType) (v1.Image, error) {
+	layerDesc, err := partial.Descriptor(layer)
+	if err != nil {
+		return nil, fmt.Errorf("error getting layer descriptor: %w", err)
+	}
+	layerDesc.Annotations = r.layerAnnotations()
+
+	configDigest, configSize, err := v1.SHA256(bytes.NewReader(emptyConfig))
+	if err != nil {
+		return nil, fmt.Errorf("error hashing config: %w", err)
+	}
+
+	subject := r.TargetDesc
+	raw, err := json.Marshal(imageManifest{
+		SchemaVersion: 2,
+		MediaType:     mediaType,
+		ArtifactType:  artifactType,
+		Config: v1.Descriptor{
+			MediaType: configMediaType,
+			Size:      configSize,
+			Digest:    configDigest,
+		},
+		Layers:      []v1.Descriptor{*layerDesc},
+		Subject:     &subject,
+		Annotations: r.Annotations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding manifest: %w", err)
+	}
+
+	img, err := partial.CompressedToImage(&rawImage{manifest: raw, mediaType: mediaType, layer: layer})
+	if err != nil {
+		return nil, fmt.Errorf("error creating image: %w", err)
+	}
+	return img, nil
+}
+
+// rawIndex is the index with the given manifest.
+type rawIndex struct {
+	manifest []byte
+}
+
+func (i rawIndex) RawManifest() ([]byte, error) {
+	return i.manifest, nil
+}
+
+func (i rawIndex) MediaType() (ctypes.MediaType, error) {
+	return ctypes.OCIImageIndex, nil
+}
+
+// fixFallbackArtifactType sets the artifact type of the referrer in the index tagged with the fallback tag.
+// remote.Write uses the config media type as the artifact type, which is the empty config with ManifestStyleImage.
+func fixFallbackArtifactType(ctx context.Context, subject, tag name.Digest, artifactType ctypes.MediaType, opts RegistryOptions) error {
+	fallback := FallbackTag(subject)
+
+	var index *v1.IndexManifest
+	err := opts.Retry(ctx, func() error {
+		idx, err := remote.Index(fallback, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		index, err = idx.IndexManifest()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching fallback tag: %w", err)
+	}
+
+	for i, desc := range index.Manifests {
+		if desc.Digest.String() == tag.DigestStr() {
+			index.Manifests[i].ArtifactType = string(artifactType)
+		}
+	}
+
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("error encoding fallback tag: %w", err)
+	}
+
+	err = opts.Retry(ctx, func() error {
+		return remote.Put(fallback, rawIndex{manifest: raw}, opts.RemoteOptions(ctx)...)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating fallback tag: %w", err)
+	}
+
+	return nil
+}
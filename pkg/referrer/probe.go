@@ -0,0 +1,59 @@
+package referrer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/sbom"
+)
+
+// inputProbe has the fields telling the JSON inputs apart, so that the input is decoded once to detect its type
+// and then only by the decoder of the detected type.
+type inputProbe struct {
+	// dsseEnvelope has the payload type and the payload of a signed attestation.
+	dsseEnvelope
+	// Type is the statement type of an in-toto attestation.
+	Type string `json:"_type"`
+	// Context is the JSON-LD context of an OpenVEX document.
+	Context string `json:"@context"`
+	// BOMFormat is "CycloneDX" in a CycloneDX JSON SBOM.
+	BOMFormat string `json:"bomFormat"`
+	// SPDXID is the ID of the document in an SPDX JSON SBOM.
+	SPDXID string `json:"SPDXID"`
+
+	// isJSON is false if the input is not a JSON object, such as CycloneDX XML and SPDX tag-value.
+	isJSON bool
+}
+
+func probeInput(b []byte) inputProbe {
+	var p inputProbe
+	if err := json.Unmarshal(b, &p); err != nil {
+		return inputProbe{}
+	}
+	p.isJSON = true
+	return p
+}
+
+func (p inputProbe) isAttestation() bool {
+	return p.PayloadType == MediaKeyInToto || strings.HasPrefix(p.Type, inTotoStatementTypePrefix)
+}
+
+func (p inputProbe) isOpenVEX() bool {
+	return strings.HasPrefix(p.Context, openVEXContextPrefix)
+}
+
+// sbomFormat returns the SBOM format of the input.
+// sbom.DetectFormat, which decodes the input up to 4 times, is only used for the inputs other than JSON.
+func (p inputProbe) sbomFormat(b []byte) (sbom.Format, error) {
+	switch {
+	case !p.isJSON:
+		return sbom.DetectFormat(bytes.NewReader(b))
+	case p.BOMFormat == "CycloneDX":
+		return sbom.FormatCycloneDXJSON, nil
+	case strings.HasPrefix(p.SPDXID, "SPDX"):
+		return sbom.FormatSPDXJSON, nil
+	default:
+		return sbom.FormatUnknown, nil
+	}
+}
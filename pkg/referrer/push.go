@@ -0,0 +1,206 @@
+package referrer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+var fallbackTagLocks sync.Map
+
+// fallbackTagLock returns the lock serializing the updates of the fallback tag of the subject.
+func fallbackTagLock(subject name.Digest) *sync.Mutex {
+	mu, _ := fallbackTagLocks.LoadOrStore(FallbackTag(subject).String(), &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// dockerHubRegistries are the hosts of Docker Hub. go-containerregistry normalizes docker.io to index.docker.io.
+var dockerHubRegistries = map[string]bool{
+	name.DefaultRegistry:   true,
+	"docker.io":            true,
+	"registry-1.docker.io": true,
+}
+
+// warnDockerHub warns that the referrers pushed to Docker Hub may not be discoverable,
+// since its support of the referrers API has been incomplete.
+func warnDockerHub(repo name.Digest) {
+	if !dockerHubRegistries[repo.RegistryStr()] {
+		return
+	}
+	log.Logger.Warnf("Docker Hub has limited support of the referrers API, so the referrer may not be discoverable with it. "+
+		"Clients can find the referrer with the fallback tag %s", FallbackTag(repo).String())
+}
+
+// Push pushes the referrer to the repository of the subject.
+// On registries without the referrers API, the referrer is tracked with the fallback tag.
+func Push(ctx context.Context, ref *Referrer, opts Options) error {
+	img, err := ref.Image()
+	if err != nil {
+		return fmt.Errorf("error getting image: %w", err)
+	}
+
+	tag, err := ref.Tag(img)
+	if err != nil {
+		return fmt.Errorf("error getting tag: %w", err)
+	}
+
+	var artifactType ctypes.MediaType
+	if ref.ManifestStyle == ManifestStyleImage {
+		artifactType = ref.artifactType()
+	}
+
+	return pushImage(ctx, ref.TargetRepo, tag, img, artifactType, opts)
+}
+
+// PushImage pushes the referrer image built beforehand, such as the one read from an OCI image layout, to the digest reference.
+// The subject and the artifact type are taken from the manifest.
+func PushImage(ctx context.Context, tag name.Digest, img v1.Image, opts Options) error {
+	raw, err := img.RawManifest()
+	if err != nil {
+		return fmt.Errorf("error getting manifest: %w", err)
+	}
+
+	var manifest imageManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return fmt.Errorf("error parsing manifest: %w", err)
+	}
+	if manifest.Subject == nil {
+		return fmt.Errorf("the image %s has no subject and isn't a referrer", tag.String())
+	}
+
+	// Only the empty config needs the artifact type fixed in the fallback tag.
+	var artifactType ctypes.MediaType
+	if manifest.Config.MediaType == MediaKeyEmpty {
+		artifactType = manifest.ArtifactType
+	}
+
+	subject := tag.Context().Digest(manifest.Subject.Digest.String())
+	return pushImage(ctx, subject, tag, img, artifactType, opts)
+}
+
+// pushImage pushes the referrer image of the subject.
+// The artifact type is fixed in the fallback tag if not empty.
+func pushImage(ctx context.Context, subject, tag name.Digest, img v1.Image, artifactType ctypes.MediaType, opts Options) error {
+	warnDockerHub(subject)
+
+	supported, err := ReferrersAPISupported(ctx, subject, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error checking referrers API support: %w", err)
+	}
+	if !supported {
+		// remote.Write updates the index tagged with the fallback tag when the manifest has a subject.
+		log.Logger.Infof("The registry doesn't support the referrers API, the referrer is tracked with the fallback tag %s", FallbackTag(subject).String())
+
+		// The fallback tag is updated by read-modify-write, so concurrent pushes for the same subject would lose referrers.
+		mu := fallbackTagLock(subject)
+		mu.Lock()
+		defer mu.Unlock()
+	}
+
+	log.Logger.Infof("Pushing referrer to %s", tag.String())
+
+	err = opts.Retry(ctx, func() error {
+		return write(ctx, tag, img, opts)
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing referrer: %w", authError(tag.RegistryStr(), err))
+	}
+
+	if !supported && artifactType != "" {
+		if err := fixFallbackArtifactType(ctx, subject, tag, artifactType, opts.RegistryOptions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// write pushes the image, reporting the upload progress to Options.Progress if set.
+func write(ctx context.Context, tag name.Digest, img v1.Image, opts Options) error {
+	if opts.Progress == nil {
+		return remote.Write(tag, img, opts.RemoteOptions(ctx)...)
+	}
+
+	// remote.Write closes the channel when it returns, so a new channel is needed for each attempt.
+	// It leaves the channel open if it fails before starting the upload, so the consumer is also stopped by stop.
+	updates := make(chan v1.Update, 100)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	report := func(update v1.Update) {
+		if update.Error == nil {
+			opts.Progress(update)
+		}
+	}
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				report(update)
+			case <-stop:
+				// No more updates are sent once remote.Write returns, so the buffered ones are reported without waiting.
+				for {
+					select {
+					case update, ok := <-updates:
+						if !ok {
+							return
+						}
+						report(update)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	err := remote.Write(tag, img, append(opts.RemoteOptions(ctx), remote.WithProgress(updates))...)
+	close(stop)
+	<-done
+
+	return err
+}
+
+// PushTag tags the pushed referrer image with the tag, in addition to the digest.
+// The tag is only a convenience for manual inspection and doesn't affect the referrers of the subject.
+func PushTag(ctx context.Context, tag name.Tag, img v1.Image, opts RegistryOptions) error {
+	err := opts.Retry(ctx, func() error {
+		return remote.Tag(tag, img, opts.RemoteOptions(ctx)...)
+	})
+	if err != nil {
+		return fmt.Errorf("error tagging referrer: %w", authError(tag.RegistryStr(), err))
+	}
+
+	return nil
+}
+
+// TagExisting tags the referrer already present at the digest with the tag, without pushing it again.
+func TagExisting(ctx context.Context, tag name.Tag, digest name.Digest, opts RegistryOptions) error {
+	var desc *remote.Descriptor
+	err := opts.Retry(ctx, func() (err error) {
+		desc, err = remote.Get(digest, opts.RemoteOptions(ctx)...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching referrer %s: %w", digest.String(), authError(digest.RegistryStr(), err))
+	}
+
+	err = opts.Retry(ctx, func() error {
+		return remote.Tag(tag, desc, opts.RemoteOptions(ctx)...)
+	})
+	if err != nil {
+		return fmt.Errorf("error tagging referrer: %w", authError(tag.RegistryStr(), err))
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// newFallbackRegistry starts an in-memory registry without the referrers API, returning 404 for the referrers endpoint.
+func newFallbackRegistry(t *testing.T) string {
+	t.Helper()
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	return serveTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if elems := strings.Split(r.URL.Path, "/"); len(elems) > 3 && elems[len(elems)-2] == "referrers" {
+			http.NotFound(w, r)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+}
+
+// fetchFallbackIndex returns the index tagged with the fallback tag of the subject.
+func fetchFallbackIndex(t *testing.T, subject name.Digest) *v1.IndexManifest {
+	t.Helper()
+	idx, err := remote.Index(FallbackTag(subject))
+	if err != nil {
+		t.Fatalf("error fetching fallback tag %s: %s", FallbackTag(subject), err)
+	}
+	index, err := idx.IndexManifest()
+	if err != nil {
+		t.Fatalf("error parsing fallback index: %s", err)
+	}
+	return index
+}
+
+func TestPushFallbackTag(t *testing.T) {
+	tests := []struct {
+		name          string
+		manifestStyle string
+		artifactType  ctypes.MediaType
+	}{
+		{
+			name:          "artifact style",
+			manifestStyle: ManifestStyleArtifact,
+			artifactType:  MediaKeyCycloneDX,
+		},
+		{
+			// The artifact type is fixed by fixFallbackArtifactType, since remote.Write uses the empty config media type.
+			name:          "image style",
+			manifestStyle: ManifestStyleImage,
+			artifactType:  MediaKeyCycloneDX,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			host := newFallbackRegistry(t)
+			subject := pushTestImage(t, host, "app")
+
+			supported, err := ReferrersAPISupported(ctx, subject, RegistryOptions{})
+			if err != nil {
+				t.Fatalf("ReferrersAPISupported() error = %s", err)
+			}
+			if supported {
+				t.Fatalf("ReferrersAPISupported() = true, want false")
+			}
+
+			ref, err := BuildReferrer(ctx, bytes.NewReader(loadFixture(t, "cyclonedx.json", subject)), Options{})
+			if err != nil {
+				t.Fatalf("BuildReferrer() error = %s", err)
+			}
+			ref.ManifestStyle = tt.manifestStyle
+			if err := Push(ctx, ref, Options{}); err != nil {
+				t.Fatalf("Push() error = %s", err)
+			}
+
+			img, err := ref.Image()
+			if err != nil {
+				t.Fatalf("Image() error = %s", err)
+			}
+			tag, err := ref.Tag(img)
+			if err != nil {
+				t.Fatalf("Tag() error = %s", err)
+			}
+
+			if got := FallbackTag(subject).TagStr(); got != strings.Replace(subject.DigestStr(), ":", "-", 1) {
+				t.Errorf("FallbackTag() = %s, want sha256-<digest>", got)
+			}
+			index := fetchFallbackIndex(t, subject)
+			if len(index.Manifests) != 1 {
+				t.Fatalf("fallback index has %d manifests, want 1", len(index.Manifests))
+			}
+			desc := index.Manifests[0]
+			if desc.Digest.String() != tag.DigestStr() {
+				t.Errorf("fallback index lists %s, want %s", desc.Digest, tag.DigestStr())
+			}
+			if ctypes.MediaType(desc.ArtifactType) != tt.artifactType {
+				t.Errorf("artifact type = %s, want %s", desc.ArtifactType, tt.artifactType)
+			}
+
+			// FetchReferrers reads the fallback tag on this registry.
+			referrers, err := FetchReferrers(ctx, subject, RegistryOptions{})
+			if err != nil {
+				t.Fatalf("FetchReferrers() error = %s", err)
+			}
+			if len(referrers.Manifests) != 1 || referrers.Manifests[0].Digest.String() != tag.DigestStr() {
+				t.Errorf("FetchReferrers() = %v, want only %s", referrers.Manifests, tag.DigestStr())
+			}
+		})
+	}
+}
+
+// brokenImage fails to list its layers, so that remote.Write fails before starting the upload.
+type brokenImage struct {
+	v1.Image
+}
+
+func (brokenImage) Layers() ([]v1.Layer, error) {
+	return nil, errors.New("broken layers")
+}
+
+func TestWriteProgressEarlyFailure(t *testing.T) {
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("error creating image: %s", err)
+	}
+	tag := testSubject(t)
+	opts := Options{Progress: func(v1.Update) {}}
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if err := write(context.Background(), tag, brokenImage{Image: img}, opts); err == nil {
+			t.Fatal("write() error = nil, want the error of the layers")
+		}
+	}
+	// The progress consumer of each attempt must have returned along with write.
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines = %d after the failed writes, want at most %d", after, before)
+	}
+}
@@ -0,0 +1,81 @@
+package referrer
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+)
+
+// MaxRetryAfter is the maximum wait for the Retry-After header of the rate-limited responses.
+const MaxRetryAfter = time.Minute
+
+// retryAfterTransport retries the requests rate-limited with 429 after the duration in the Retry-After header,
+// which registries such as Docker Hub return, or with the exponential backoff without the header.
+// It is the only layer retrying 429, so that the retries of rate-limited requests aren't multiplied with Retry.
+type retryAfterTransport struct {
+	inner      http.RoundTripper
+	maxRetries int
+	delay      time.Duration
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		// The request can't be replayed if the body can't be rewound.
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			d = backoff(t.delay, attempt)
+		}
+		if d > MaxRetryAfter {
+			d = MaxRetryAfter
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.Logger.Warnf("Rate limited by %s, retrying in %s (%d/%d)", req.URL.Host, d, attempt+1, t.maxRetries)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(d):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// parseRetryAfter parses the Retry-After header, which is either the seconds or the HTTP date to wait until.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,127 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestPushRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		limited    int32
+		maxRetries int
+		wantErr    bool
+		want       int32
+	}{
+		{
+			name:       "retried after Retry-After",
+			limited:    1,
+			maxRetries: 3,
+			want:       2,
+		},
+		{
+			name:       "retries exhausted",
+			limited:    100,
+			maxRetries: 2,
+			wantErr:    true,
+			// Retry doesn't retry the 429 on top of retryAfterTransport.
+			want: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var enabled atomic.Bool
+			var attempts atomic.Int32
+			reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+			host := serveTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The manifest of the referrer is rate-limited, while the fallback tag is of sha256-<digest>.
+				if enabled.Load() && r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/sha256:") {
+					if attempts.Add(1) <= tt.limited {
+						w.Header().Set("Retry-After", "0")
+						w.WriteHeader(http.StatusTooManyRequests)
+						return
+					}
+				}
+				reg.ServeHTTP(w, r)
+			}))
+			subject := pushTestImage(t, host, "app")
+			enabled.Store(true)
+
+			ctx := context.Background()
+			opts := Options{RegistryOptions: RegistryOptions{MaxRetries: tt.maxRetries, RetryDelay: time.Millisecond}}
+			ref, err := BuildReferrer(ctx, bytes.NewReader(loadFixture(t, "cyclonedx.json", subject)), opts)
+			if err != nil {
+				t.Fatalf("BuildReferrer() error = %s", err)
+			}
+			err = Push(ctx, ref, opts)
+			if tt.wantErr && err == nil {
+				t.Fatal("Push() error = nil, want 429")
+			} else if !tt.wantErr && err != nil {
+				t.Fatalf("Push() error = %s", err)
+			}
+			if got := attempts.Load(); got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2023, 4, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{
+			name:   "seconds",
+			value:  "30",
+			want:   30 * time.Second,
+			wantOK: true,
+		},
+		{
+			name:   "HTTP date",
+			value:  "Sat, 01 Apr 2023 00:01:00 GMT",
+			want:   time.Minute,
+			wantOK: true,
+		},
+		{
+			name:   "past HTTP date",
+			value:  "Fri, 31 Mar 2023 23:59:00 GMT",
+			want:   0,
+			wantOK: true,
+		},
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:  "negative seconds",
+			value: "-1",
+		},
+		{
+			name:  "invalid",
+			value: "soon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, %t, want %s, %t", tt.value, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
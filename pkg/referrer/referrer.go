@@ -0,0 +1,631 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/aquasecurity/trivy/pkg/purl"
+	"github.com/aquasecurity/trivy/pkg/report/predicate"
+	"github.com/aquasecurity/trivy/pkg/sbom"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/package-url/packageurl-go"
+)
+
+const (
+	// ref. https://github.com/opencontainers/image-spec/blob/dd7fd714f5406d39db5fd0602a0e6090929dc85e/annotations.md#pre-defined-annotation-keys
+	AnnotationKeyCreated     = "org.opencontainers.artifact.created"
+	AnnotationKeyDescription = "org.opencontainers.artifact.description"
+	// ref. https://github.com/opencontainers/image-spec/blob/main/annotations.md#pre-defined-annotation-keys
+	AnnotationKeyImageCreated = "org.opencontainers.image.created"
+	AnnotationKeyTitle        = "org.opencontainers.image.title"
+
+	// Use a Media Type registered with IANA.
+	// ref. https://github.com/opencontainers/image-spec/blob/dd7fd714f5406d39db5fd0602a0e6090929dc85e/artifact.md#artifact-manifest-property-descriptions
+	// ref. https://www.iana.org/assignments/media-types/media-types.xhtml
+	MediaKeyCycloneDX    = "application/vnd.cyclonedx+json"
+	MediaKeyCycloneDXXML = "application/vnd.cyclonedx+xml"
+	MediaKeySPDX         = "application/spdx+json"
+	MediaKeySPDXTV       = "text/spdx"
+	// 2023/4/4: Since there is no MediaType specialized for vulnerability information registered with IANA, we use the json type.
+	MediaKeyCosignVuln = "application/json"
+)
+
+// Types of the input.
+const (
+	TypeSBOM          = "sbom"
+	TypeVulnerability = "vulnerability"
+	TypeAttestation   = "attestation"
+	TypeVEX           = "vex"
+)
+
+var errFailedSBOMDetection = fmt.Errorf("failed to detect SBOM")
+var errFailedVulnDetection = fmt.Errorf("failed to detect Cosign Vulnerability")
+
+// Referrer is an artifact such as an SBOM to be attached to the subject image.
+type Referrer struct {
+	// Annotations are set to the referrer manifest.
+	Annotations map[string]string
+	// MediaType is the media type of the referrer content.
+	MediaType ctypes.MediaType
+	// ArtifactType overrides the artifact type of the referrer, which defaults to MediaType.
+	ArtifactType ctypes.MediaType
+	// Bytes is the content of the referrer, such as the SBOM.
+	Bytes []byte
+	// Title is set to the title annotation of the layer, such as the file name of the content, if not empty.
+	Title string
+	// Compress stores the content as a gzip-compressed layer.
+	Compress bool
+	// ManifestStyle is either ManifestStyleArtifact or ManifestStyleImage. It defaults to ManifestStyleArtifact.
+	ManifestStyle string
+	// EmptyConfig stores the config of the artifact type as `{}` with ManifestStyleArtifact,
+	// instead of the config of the empty image. The config is always `{}` with ManifestStyleImage.
+	EmptyConfig bool
+	// ManifestMediaType overrides the media type of the referrer manifest with ManifestStyleArtifact,
+	// which defaults to the media type of a Docker image subject, or the OCI image manifest otherwise.
+	ManifestMediaType ctypes.MediaType
+	// TargetRepo is the digest reference of the subject.
+	TargetRepo name.Digest
+	// TargetDesc is the descriptor of the subject.
+	TargetDesc v1.Descriptor
+}
+
+// Image returns the referrer manifest with the content as the only layer.
+func (r *Referrer) Image() (v1.Image, error) {
+	layer, err := r.layer()
+	if err != nil {
+		return nil, err
+	}
+
+	artifactType := r.artifactType()
+	if r.ManifestStyle == ManifestStyleImage {
+		return r.emptyConfigImage(layer, ctypes.OCIManifestSchema1, MediaKeyEmpty, artifactType)
+	}
+
+	manifestMediaType := r.manifestMediaType()
+	if r.EmptyConfig {
+		// The config media type is used as the artifact type of the referrer.
+		return r.emptyConfigImage(layer, manifestMediaType, artifactType, "")
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       layer,
+		Annotations: r.layerAnnotations(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error appending layer: %w", err)
+	}
+
+	img = mutate.MediaType(img, manifestMediaType)
+	// The config media type is used as the artifact type of the referrer.
+	img = mutate.ConfigMediaType(img, artifactType)
+	img = mutate.Annotations(img, r.Annotations).(v1.Image)
+	img = mutate.Subject(img, r.TargetDesc).(v1.Image)
+
+	return img, nil
+}
+
+// manifestMediaType returns the media type of the referrer manifest with ManifestStyleArtifact.
+// Only the Docker image subject is followed, since the referrer of an index or an artifact is still an image manifest.
+func (r *Referrer) manifestMediaType() ctypes.MediaType {
+	switch {
+	case r.ManifestMediaType != "":
+		return r.ManifestMediaType
+	case r.TargetDesc.MediaType == ctypes.DockerManifestSchema2:
+		return ctypes.DockerManifestSchema2
+	default:
+		return ctypes.OCIManifestSchema1
+	}
+}
+
+// artifactType returns ArtifactType, or MediaType if it isn't set.
+func (r *Referrer) artifactType() ctypes.MediaType {
+	if r.ArtifactType != "" {
+		return r.ArtifactType
+	}
+	return r.MediaType
+}
+
+// layerAnnotations returns the annotations of the layer.
+func (r *Referrer) layerAnnotations() map[string]string {
+	if r.Title == "" {
+		return nil
+	}
+	return map[string]string{AnnotationKeyTitle: r.Title}
+}
+
+// layer returns the layer holding the content.
+// The compressed layer has the OCI gzip media type, and its diff ID is computed from the uncompressed content.
+func (r *Referrer) layer() (v1.Layer, error) {
+	if !r.Compress {
+		return static.NewLayer(r.Bytes, r.MediaType), nil
+	}
+
+	b, err := compress(r.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	layer, err := partial.CompressedToLayer(static.NewLayer(b, ctypes.OCILayer))
+	if err != nil {
+		return nil, fmt.Errorf("error creating compressed layer: %w", err)
+	}
+	return layer, nil
+}
+
+// Tag returns the digest reference to push the referrer image to.
+func (r *Referrer) Tag(img v1.Image) (name.Digest, error) {
+	digest, err := img.Digest()
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error getting image digest: %w", err)
+	}
+
+	// Derive the tag from the target repository so that the registry options such as insecure are kept.
+	return r.TargetRepo.Context().Digest(digest.String()), nil
+}
+
+// isImagePurlType reports whether the purl type identifies an image.
+// The version of the other purl types, such as pkg:golang, isn't an image tag or digest.
+func isImagePurlType(t string) bool {
+	return t == packageurl.TypeOCI || t == packageurl.TypeDocker
+}
+
+// repoFromPurl returns the image identified by the purl.
+// The version is either a digest or a tag, which needs to be resolved to the digest with resolveDigest.
+func repoFromPurl(purlStr string, opts ...name.Option) (name.Reference, error) {
+	p, err := purl.FromString(purlStr)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing purl: %w", err)
+	}
+
+	if !isImagePurlType(p.Type) {
+		return nil, fmt.Errorf("purl %s is not an OCI artifact: the type must be %s or %s, not %s", purlStr, packageurl.TypeOCI, packageurl.TypeDocker, p.Type)
+	}
+
+	url := p.Qualifiers.Map()["repository_url"]
+	if url == "" {
+		return nil, fmt.Errorf("repository_url not found")
+	}
+
+	if p.Version == "" {
+		return nil, fmt.Errorf("SBOM subject purl has no version; cannot attach referrer without a digest")
+	}
+
+	if _, err := v1.NewHash(p.Version); err != nil {
+		tag, err := name.NewTag(fmt.Sprintf("%s:%s", url, p.Version), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating new tag: %w", err)
+		}
+		return tag, nil
+	}
+
+	digest, err := name.NewDigest(fmt.Sprintf("%s@%s", url, p.Version), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating new digest: %w", err)
+	}
+
+	return digest, nil
+}
+
+// sbomDocument is the SBOM decoded once, from which the stats, the title and the subject are taken.
+type sbomDocument struct {
+	format sbom.Format
+	// bom is set for CycloneDX, and spdx for SPDX.
+	bom  *cdx.BOM
+	spdx spdxDocument
+}
+
+func decodeSBOM(b []byte, format sbom.Format) (sbomDocument, error) {
+	doc := sbomDocument{format: format}
+	switch format {
+	case sbom.FormatCycloneDXJSON, sbom.FormatCycloneDXXML:
+		fileFormat := cdx.BOMFileFormatJSON
+		if format == sbom.FormatCycloneDXXML {
+			fileFormat = cdx.BOMFileFormatXML
+		}
+		doc.bom = cdx.NewBOM()
+		if err := cdx.NewBOMDecoder(bytes.NewReader(b), fileFormat).Decode(doc.bom); err != nil {
+			return sbomDocument{}, fmt.Errorf("error decoding SBOM: %w", err)
+		}
+	case sbom.FormatSPDXJSON, sbom.FormatSPDXTV:
+		parse := parseSpdxJSON
+		if format == sbom.FormatSPDXTV {
+			parse = parseSpdxTV
+		}
+		spdx, err := parse(b)
+		if err != nil {
+			return sbomDocument{}, fmt.Errorf("error decoding SBOM: %w", err)
+		}
+		doc.spdx = spdx
+	default:
+		return sbomDocument{}, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	return doc, nil
+}
+
+func tryReferrerFromSBOM(ctx context.Context, b []byte, p inputProbe, opts Options) (*Referrer, error) {
+	format, err := p.sbomFormat(b)
+	if format == sbom.FormatUnknown {
+		return nil, errFailedSBOMDetection
+	} else if err != nil {
+		return nil, fmt.Errorf("error detecting SBOM format: %w", err)
+	}
+
+	doc, err := decodeSBOM(b, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return referrerFromSBOM(ctx, b, doc, opts)
+}
+
+// referrerFromSBOM builds the referrer of the raw SBOM from the decoded document, without decoding it again.
+func referrerFromSBOM(ctx context.Context, b []byte, doc sbomDocument, opts Options) (*Referrer, error) {
+	var mediaType ctypes.MediaType
+	var anns map[string]string
+	var subject name.Reference
+	var title string
+	var err error
+
+	format := doc.format
+	switch format {
+	case sbom.FormatCycloneDXJSON, sbom.FormatCycloneDXXML:
+		bom := doc.bom
+		opts.reportStats(cycloneDXStats(format, bom))
+		if bom.Metadata != nil && bom.Metadata.Component != nil {
+			title = bom.Metadata.Component.Name
+		}
+		if !opts.hasSubject() {
+			subject, err = repoFromCycloneDX(bom, opts.NameOptions()...)
+			if err != nil {
+				return nil, fmt.Errorf("error getting repository from CycloneDX: %w", err)
+			}
+		}
+		encoding := "JSON"
+		mediaType = MediaKeyCycloneDX
+		if format == sbom.FormatCycloneDXXML {
+			encoding = "XML"
+			mediaType = MediaKeyCycloneDXXML
+		}
+		anns = map[string]string{
+			AnnotationKeyDescription: fmt.Sprintf("CycloneDX %s SBOM", encoding),
+		}
+		if isCycloneDXVEX(bom) {
+			anns[AnnotationKeyDescription] = fmt.Sprintf("CycloneDX %s VEX", encoding)
+		}
+
+	case sbom.FormatSPDXJSON, sbom.FormatSPDXTV:
+		opts.reportStats(spdxStats(format, doc.spdx))
+		title = doc.spdx.name
+		if !opts.hasSubject() {
+			subject, err = repoFromSpdx(doc.spdx, opts.NameOptions()...)
+			if err != nil {
+				return nil, fmt.Errorf("error getting repository from SPDX: %w", err)
+			}
+		}
+		anns = map[string]string{
+			AnnotationKeyDescription: "SPDX JSON SBOM",
+		}
+		mediaType = MediaKeySPDX
+		if format == sbom.FormatSPDXTV {
+			anns[AnnotationKeyDescription] = "SPDX tag-value SBOM"
+			mediaType = MediaKeySPDXTV
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	log.Logger.Infof("SBOM detected: %s", format)
+
+	if opts.TitleFromSBOM && title != "" {
+		anns[AnnotationKeyTitle] = title
+	}
+
+	var repo name.Digest
+	if opts.hasSubject() {
+		repo, err = opts.subjectRepo(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		repo, err = resolveDigest(ctx, subject, opts.RegistryOptions)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving subject: %w", err)
+		}
+		if opts.Repository != "" {
+			repo, err = opts.withRepository(repo)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	repo, targetDesc, err := subjectDescriptor(ctx, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subject descriptor: %w", err)
+	}
+
+	return &Referrer{
+		Annotations: anns,
+		MediaType:   mediaType,
+		Bytes:       b,
+		TargetRepo:  repo,
+		TargetDesc:  targetDesc,
+	}, nil
+}
+
+func tryReferrerFromVulnerability(ctx context.Context, b []byte, opts Options) (*Referrer, error) {
+	var d predicate.CosignVulnPredicate
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal vulnerability data: %w", errFailedVulnDetection)
+	}
+
+	if len(d.Scanner.Result.Metadata.RepoDigests) == 0 {
+		return nil, fmt.Errorf("no RepoDigests found in vulnerability data: %w", errFailedVulnDetection)
+	}
+
+	var repo name.Digest
+	var err error
+	if opts.hasSubject() {
+		repo, err = opts.subjectRepo(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		repo, err = name.NewDigest(d.Scanner.Result.Metadata.RepoDigests[0], opts.NameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating new digest: %w", err)
+		}
+	}
+	if !opts.hasSubject() && opts.Repository != "" {
+		repo, err = opts.withRepository(repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo, targetDesc, err := subjectDescriptor(ctx, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching target descriptor: %w", err)
+	}
+
+	log.Logger.Infof("Cosign vulnerability data detected")
+
+	anns := map[string]string{
+		AnnotationKeyDescription: "Vulnerability Scan Report",
+	}
+	if !opts.NoTimestamp {
+		anns[AnnotationKeyCreated] = time.Now().Format(time.RFC3339)
+	}
+
+	return &Referrer{
+		Annotations: anns,
+		MediaType:   ctypes.MediaType(MediaKeyCosignVuln),
+		Bytes:       b,
+		TargetRepo:  repo,
+		TargetDesc:  targetDesc,
+	}, nil
+}
+
+// Options configures how the referrer is built and pushed.
+type Options struct {
+	RegistryOptions
+	// Subject is the image reference to attach the referrer to.
+	// A tag is resolved to its current digest. If empty, the image described in the input is used.
+	Subject string
+	// Repository replaces the repository of the subject described in the input, keeping its digest.
+	// It is ignored if Subject is given.
+	Repository string
+	// SubjectDigest is the digest of the subject in Repository, used instead of the subject described in the input.
+	SubjectDigest string
+	// SkipHead builds the descriptor of the subject from SubjectDigest, SubjectMediaType and SubjectSize
+	// instead of fetching it, for registries where HEAD requests fail.
+	SkipHead         bool
+	SubjectMediaType ctypes.MediaType
+	SubjectSize      int64
+	// Platform selects the child image when the subject is an image index.
+	Platform *v1.Platform
+	// RequireImage rejects the subject which is an image index unless Platform selects an image in it.
+	RequireImage bool
+	// Progress is called with the upload progress of Push if set.
+	Progress func(v1.Update)
+	// Type forces the type of the input (sbom, vulnerability, attestation or vex) instead of detecting it.
+	Type string
+	// NoTimestamp omits the created timestamp annotations for reproducible referrers.
+	NoTimestamp bool
+	// TitleFromSBOM sets the title annotation of the referrer to the name of the SBOM,
+	// the name of the component in the metadata of CycloneDX or the document name of SPDX.
+	TitleFromSBOM bool
+	// KeepAttestation puts an SBOM attestation as is, instead of the SBOM in its predicate.
+	KeepAttestation bool
+	// AllowChaining allows attaching the referrer to a subject which is itself a referrer.
+	AllowChaining bool
+	// AllowAnySubject allows attaching the referrer to any manifest, such as an artifact pushed with ORAS,
+	// not only to images and image indexes.
+	AllowAnySubject bool
+	// MaxSize is the maximum size of the input in bytes, after decompression. Zero means no limit.
+	MaxSize int64
+	// Stats is called with the summary of the SBOM after it is decoded if set.
+	Stats func(SBOMStats)
+}
+
+// withTimestamp sets the time the referrer is created unless NoTimestamp is set.
+func (o Options) withTimestamp(ref *Referrer) *Referrer {
+	if !o.NoTimestamp {
+		ref.Annotations[AnnotationKeyImageCreated] = time.Now().UTC().Format(time.RFC3339)
+	}
+	return ref
+}
+
+// hasSubject reports whether the subject is given explicitly instead of the one described in the input.
+func (o Options) hasSubject() bool {
+	return o.Subject != "" || o.SubjectDigest != ""
+}
+
+// subjectRepo returns the subject given explicitly instead of the one described in the input.
+// A tag is resolved to the digest it currently points to.
+func (o Options) subjectRepo(ctx context.Context) (name.Digest, error) {
+	if o.SubjectDigest != "" {
+		repo, err := name.NewDigest(fmt.Sprintf("%s@%s", o.Repository, o.SubjectDigest), o.NameOptions()...)
+		if err != nil {
+			return name.Digest{}, fmt.Errorf("error parsing subject digest: %w", err)
+		}
+		return repo, nil
+	}
+
+	ref, err := name.ParseReference(o.Subject, o.NameOptions()...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error parsing subject: %w", err)
+	}
+
+	repo, err := resolveDigest(ctx, ref, o.RegistryOptions)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error resolving subject: %w", err)
+	}
+
+	return repo, nil
+}
+
+// BuildReferrer builds the referrer from the SBOM, the Cosign vulnerability report, the in-toto attestation or the VEX document read from r.
+// The type of the input is detected unless Options.Type is given.
+// Gzip-compressed input is decompressed transparently.
+// The subject is resolved from the input unless Options.Subject is given.
+func BuildReferrer(ctx context.Context, r io.Reader, opts Options) (*Referrer, error) {
+	b, err := readLimited(r, opts.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err = decompress(b, opts.MaxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkInput(b); err != nil {
+		return nil, err
+	}
+
+	// The input is decoded once to detect its type, and then only by the decoder of the type.
+	p := probeInput(b)
+
+	var ref *Referrer
+	switch opts.Type {
+	case "":
+		ref, err = detectReferrer(ctx, b, p, opts)
+	case TypeSBOM:
+		ref, err = tryReferrerFromSBOM(ctx, b, p, opts)
+	case TypeVulnerability:
+		ref, err = tryReferrerFromVulnerability(ctx, b, opts)
+	case TypeAttestation:
+		ref, err = tryReferrerFromAttestation(ctx, b, p, opts)
+	case TypeVEX:
+		ref, err = tryReferrerFromVEX(ctx, b, p, opts)
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", opts.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return opts.withTimestamp(ref), nil
+}
+
+// DefaultMaxSize is the default of Options.MaxSize.
+const DefaultMaxSize = 64 << 20
+
+// errTooLarge is returned when the input exceeds Options.MaxSize.
+var errTooLarge = errors.New("the input is too large")
+
+// readLimited reads r up to max bytes so that an unexpectedly large input isn't buffered unboundedly.
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading: %w", err)
+		}
+		return b, nil
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading: %w", err)
+	}
+	if int64(len(b)) > max {
+		return nil, fmt.Errorf("%w: exceeds the limit of %d bytes, use --max-layer-size to raise it", errTooLarge, max)
+	}
+
+	return b, nil
+}
+
+// checkInput returns a clear error for the empty or truncated input,
+// which is typical when the command generating the input fails, instead of failing the detection.
+func checkInput(b []byte) error {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 {
+		return fmt.Errorf("no SBOM data received on input")
+	}
+
+	// json.Valid doesn't build the values, which matters for large SBOMs. They are decoded only for the error.
+	if (trimmed[0] == '{' || trimmed[0] == '[') && !json.Valid(trimmed) {
+		var v any
+		err := json.Unmarshal(trimmed, &v)
+		return fmt.Errorf("the input is not valid JSON, it may be truncated: %w", err)
+	}
+
+	return nil
+}
+
+// detectReferrer builds the referrer from the type the input is detected as with the probe.
+func detectReferrer(ctx context.Context, b []byte, p inputProbe, opts Options) (*Referrer, error) {
+	// Attestations are detected first, since an attestation of a CycloneDX SBOM is also detected as an SBOM.
+	switch {
+	case p.isAttestation():
+		ref, err := tryReferrerFromAttestation(ctx, b, p, opts)
+		if err == nil {
+			return ref, nil
+		} else if !errors.Is(err, errFailedAttestationDetection) {
+			return nil, fmt.Errorf("error processing attestation: %w", err)
+		}
+	case p.isOpenVEX():
+		ref, err := tryReferrerFromVEX(ctx, b, p, opts)
+		if err == nil {
+			return ref, nil
+		} else if !errors.Is(err, errFailedVEXDetection) {
+			return nil, fmt.Errorf("error processing VEX: %w", err)
+		}
+	}
+
+	ref, err := tryReferrerFromSBOM(ctx, b, p, opts)
+	if err == nil {
+		return ref, nil
+	} else if !errors.Is(err, errFailedSBOMDetection) {
+		return nil, fmt.Errorf("error processing SBOM: %w", err)
+	}
+
+	log.Logger.Infof("Failed to detect a valid SBOM: ensure the provided SBOM is generated by Trivy, as only Trivy-generated SBOMs are currently supported")
+
+	ref, err = tryReferrerFromVulnerability(ctx, b, opts)
+	if err == nil {
+		return ref, nil
+	} else if !errors.Is(err, errFailedVulnDetection) {
+		return nil, fmt.Errorf("error processing vulnerability: %w", err)
+	}
+
+	log.Logger.Infof("Failed to detect Cosign vulnerability format")
+
+	return nil, fmt.Errorf("failed to detect referrer type")
+}
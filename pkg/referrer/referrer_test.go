@@ -0,0 +1,268 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestBuildReferrerAndPush(t *testing.T) {
+	tests := []struct {
+		name        string
+		fixture     string
+		mediaType   ctypes.MediaType
+		description string
+	}{
+		{
+			name:        "CycloneDX JSON",
+			fixture:     "cyclonedx.json",
+			mediaType:   MediaKeyCycloneDX,
+			description: "CycloneDX JSON SBOM",
+		},
+		{
+			name:        "CycloneDX XML",
+			fixture:     "cyclonedx.xml",
+			mediaType:   MediaKeyCycloneDXXML,
+			description: "CycloneDX XML SBOM",
+		},
+		{
+			name:        "SPDX JSON",
+			fixture:     "spdx.json",
+			mediaType:   MediaKeySPDX,
+			description: "SPDX JSON SBOM",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			host := newTestRegistry(t, registry.WithReferrersSupport(true))
+			subject := pushTestImage(t, host, "app")
+
+			ref, err := BuildReferrer(ctx, bytes.NewReader(loadFixture(t, tt.fixture, subject)), Options{})
+			if err != nil {
+				t.Fatalf("BuildReferrer() error = %s", err)
+			}
+			if err := Push(ctx, ref, Options{}); err != nil {
+				t.Fatalf("Push() error = %s", err)
+			}
+
+			img, err := ref.Image()
+			if err != nil {
+				t.Fatalf("Image() error = %s", err)
+			}
+			tag, err := ref.Tag(img)
+			if err != nil {
+				t.Fatalf("Tag() error = %s", err)
+			}
+
+			manifest := fetchManifest(t, tag)
+			if got := descriptorDigest(manifest.Subject).String(); got != subject.DigestStr() {
+				t.Errorf("subject = %s, want %s", got, subject.DigestStr())
+			}
+			if manifest.Config.MediaType != tt.mediaType {
+				t.Errorf("config media type = %s, want %s", manifest.Config.MediaType, tt.mediaType)
+			}
+			if got := manifest.Annotations[AnnotationKeyDescription]; got != tt.description {
+				t.Errorf("description = %q, want %q", got, tt.description)
+			}
+		})
+	}
+}
+
+func TestRepoFromPurl(t *testing.T) {
+	tests := []struct {
+		name    string
+		purl    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "digest",
+			purl: "pkg:oci/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536?repository_url=ghcr.io/org/app",
+			want: "ghcr.io/org/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536",
+		},
+		{
+			name: "tag",
+			purl: "pkg:oci/app@v1.0?repository_url=ghcr.io/org/app",
+			want: "ghcr.io/org/app:v1.0",
+		},
+		{
+			name:    "no version",
+			purl:    "pkg:oci/app?repository_url=ghcr.io/org/app",
+			wantErr: "has no version",
+		},
+		{
+			name:    "missing repository_url",
+			purl:    "pkg:oci/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536",
+			wantErr: "repository_url not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repoFromPurl(tt.purl)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("repoFromPurl() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("repoFromPurl() error = %s", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("repoFromPurl() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferrerManifestMediaType(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject ctypes.MediaType
+		want    ctypes.MediaType
+	}{
+		{
+			name:    "Docker image",
+			subject: ctypes.DockerManifestSchema2,
+			want:    ctypes.DockerManifestSchema2,
+		},
+		{
+			name:    "OCI image",
+			subject: ctypes.OCIManifestSchema1,
+			want:    ctypes.OCIManifestSchema1,
+		},
+		{
+			name:    "OCI index",
+			subject: ctypes.OCIImageIndex,
+			want:    ctypes.OCIManifestSchema1,
+		},
+		{
+			name:    "Docker manifest list",
+			subject: ctypes.DockerManifestList,
+			want:    ctypes.OCIManifestSchema1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := &Referrer{
+				Annotations: map[string]string{},
+				MediaType:   MediaKeyCycloneDX,
+				Bytes:       []byte("{}"),
+				TargetDesc:  v1.Descriptor{MediaType: tt.subject},
+			}
+			img, err := ref.Image()
+			if err != nil {
+				t.Fatalf("Image() error = %s", err)
+			}
+			got, err := img.MediaType()
+			if err != nil {
+				t.Fatalf("MediaType() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("manifest media type = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildReferrerInvalidInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: "no SBOM data received on input",
+		},
+		{
+			name:    "whitespace only",
+			input:   " \n\t\n",
+			wantErr: "no SBOM data received on input",
+		},
+		{
+			name:    "truncated JSON",
+			input:   `{"bomFormat": "CycloneDX", "specVersion": "1.4", "components": [{"type": "library", "name": "ba`,
+			wantErr: "the input is not valid JSON, it may be truncated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildReferrer(context.Background(), strings.NewReader(tt.input), Options{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("BuildReferrer() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReferrerReproducible(t *testing.T) {
+	tests := []struct {
+		name          string
+		compress      bool
+		manifestStyle string
+	}{
+		{
+			name: "artifact style",
+		},
+		{
+			name:     "compressed",
+			compress: true,
+		},
+		{
+			name:          "image style",
+			manifestStyle: ManifestStyleImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			host := newTestRegistry(t)
+			subject := pushTestImage(t, host, "app")
+			b := loadFixture(t, "cyclonedx.json", subject)
+
+			// --reproducible omits the created timestamps.
+			opts := Options{NoTimestamp: true}
+			var digests []string
+			for i := 0; i < 2; i++ {
+				ref, err := BuildReferrer(ctx, bytes.NewReader(b), opts)
+				if err != nil {
+					t.Fatalf("BuildReferrer() error = %s", err)
+				}
+				for _, key := range []string{AnnotationKeyCreated, AnnotationKeyImageCreated} {
+					if v, ok := ref.Annotations[key]; ok {
+						t.Errorf("annotation %s = %s, want none", key, v)
+					}
+				}
+				ref.Compress = tt.compress
+				ref.ManifestStyle = tt.manifestStyle
+
+				img, err := ref.Image()
+				if err != nil {
+					t.Fatalf("Image() error = %s", err)
+				}
+				tag, err := ref.Tag(img)
+				if err != nil {
+					t.Fatalf("Tag() error = %s", err)
+				}
+				digests = append(digests, tag.DigestStr())
+			}
+
+			if digests[0] != digests[1] {
+				t.Errorf("digests of the two builds = %s and %s, want the same", digests[0], digests[1])
+			}
+		})
+	}
+}
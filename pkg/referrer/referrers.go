@@ -0,0 +1,94 @@
+package referrer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// FallbackTag returns the tag used to track the referrers on registries without the referrers API.
+// ref. https://github.com/opencontainers/distribution-spec/blob/main/spec.md#referrers-tag-schema
+func FallbackTag(subject name.Digest) name.Tag {
+	return subject.Context().Tag(strings.Replace(subject.DigestStr(), ":", "-", 1))
+}
+
+// ReferrersAPISupported reports whether the registry implements the referrers API
+// by probing the referrers endpoint of the subject.
+// ref. https://github.com/opencontainers/distribution-spec/blob/main/spec.md#listing-referrers
+func ReferrersAPISupported(ctx context.Context, subject name.Digest, opts RegistryOptions) (bool, error) {
+	var supported bool
+	err := opts.Retry(ctx, func() (err error) {
+		supported, err = referrersAPISupported(ctx, subject, opts)
+		return err
+	})
+	return supported, err
+}
+
+func referrersAPISupported(ctx context.Context, subject name.Digest, opts RegistryOptions) (bool, error) {
+	repo := subject.Context()
+
+	auth, err := opts.keychain().Resolve(repo)
+	if err != nil {
+		return false, fmt.Errorf("error resolving credentials: %w", err)
+	}
+
+	tr, err := transport.NewWithContext(ctx, repo.Registry, auth, opts.transport(), []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return false, fmt.Errorf("error creating transport: %w", authError(repo.RegistryStr(), err))
+	}
+
+	u := url.URL{
+		Scheme: repo.Registry.Scheme(),
+		Host:   repo.RegistryStr(),
+		Path:   fmt.Sprintf("/v2/%s/referrers/%s", repo.RepositoryStr(), subject.DigestStr()),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", string(ctypes.OCIImageIndex))
+
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error requesting referrers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := transport.CheckError(resp, http.StatusOK, http.StatusNotFound, http.StatusBadRequest); err != nil {
+		return false, authError(repo.RegistryStr(), err)
+	}
+
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// FetchReferrers returns the referrers of the subject.
+// On registries without the referrers API, the fallback tag is read instead,
+// and a missing fallback tag means that there are no referrers yet.
+func FetchReferrers(ctx context.Context, subject name.Digest, opts RegistryOptions) (*v1.IndexManifest, error) {
+	var index *v1.IndexManifest
+	err := opts.Retry(ctx, func() (err error) {
+		index, err = remote.Referrers(subject, opts.RemoteOptions(ctx)...)
+		return err
+	})
+
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return &v1.IndexManifest{
+			SchemaVersion: 2,
+			MediaType:     ctypes.OCIImageIndex,
+		}, nil
+	} else if err != nil {
+		return nil, authError(subject.RegistryStr(), err)
+	}
+
+	return index, nil
+}
@@ -0,0 +1,253 @@
+package referrer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// The keychains selected with RegistryOptions.Keychain.
+const (
+	// KeychainDefault resolves the credentials from the Docker config and the cloud providers.
+	KeychainDefault = "default"
+	// KeychainAnonymous accesses the registry without credentials.
+	KeychainAnonymous = "anonymous"
+	// KeychainEnv reads the credentials from the REGISTRY_USERNAME and REGISTRY_PASSWORD environment variables.
+	KeychainEnv = "env"
+)
+
+// RegistryOptions configures the access to the registry.
+type RegistryOptions struct {
+	// Username and Password are used for the basic authentication.
+	Username string
+	Password string
+	// Token is used for the bearer authentication.
+	Token string
+	// Insecure allows plain HTTP and skips TLS verification.
+	Insecure bool
+	// RootCAs is the pool of the CA certificates to verify the registry with, such as a private CA.
+	// The system pool is used if nil.
+	RootCAs *x509.CertPool
+	// Certificates are the client certificates presented to the registry requiring mutual TLS.
+	Certificates []tls.Certificate
+	// InsecureRegistries are the hosts, such as localhost:5000, accessed over plain HTTP,
+	// while the others stay TLS-verified unlike Insecure.
+	InsecureRegistries []string
+	// NoProxy disables the proxy configured by the environment variables.
+	NoProxy bool
+	// DockerConfig is the directory of config.json to read the credentials from instead of ~/.docker.
+	DockerConfig string
+	// CredentialHelper is the docker credential helper to resolve the credentials with, such as ecr-login.
+	CredentialHelper string
+	// Keychain selects how the credentials are resolved when no credentials are given. Empty means KeychainDefault.
+	Keychain string
+
+	// MaxRetries is the maximum number of retries on network errors and 429/5xx responses.
+	MaxRetries int
+	// RetryDelay is the base delay of the exponential backoff between retries.
+	RetryDelay time.Duration
+	// Timeout is the timeout of the registry operations. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// Retry calls f with the configured retries.
+func (o RegistryOptions) Retry(ctx context.Context, f func() error) error {
+	return retry(ctx, o.MaxRetries, o.RetryDelay, f)
+}
+
+// WithTimeout returns a context that is canceled when the timeout elapses.
+func (o RegistryOptions) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, o.Timeout)
+}
+
+// CheckTimeout replaces the error caused by the timeout with a clear message.
+func (o RegistryOptions) CheckTimeout(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("registry operation timed out after %s: %w", o.Timeout, err)
+	}
+	return err
+}
+
+// NameOptions returns the options for parsing the image references.
+func (o RegistryOptions) NameOptions() []name.Option {
+	var opts []name.Option
+	if o.Insecure {
+		opts = append(opts, name.Insecure)
+	}
+	return opts
+}
+
+// authError replaces the error caused by missing or insufficient credentials with a message suggesting how to log in.
+func authError(registry string, err error) error {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return err
+	}
+
+	switch terr.StatusCode {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("authentication to %s failed: run `docker login %s` or pass the credentials with --username/--password: %w", registry, registry, err)
+	case http.StatusForbidden:
+		return fmt.Errorf("access to %s is denied: check that the credentials have the permission for the repository, or log in with `docker login %s` or --username/--password: %w", registry, registry, err)
+	}
+
+	return err
+}
+
+// staticKeychain resolves the same credentials for any registry.
+type staticKeychain struct {
+	auth authn.Authenticator
+}
+
+func (k staticKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return k.auth, nil
+}
+
+// keychain returns the keychain to resolve the credentials for the registry.
+// The default keychain is used unless credentials, a credential helper or another keychain are given,
+// and the credentials for Amazon ECR and Google Container Registry / Artifact Registry
+// are resolved from the credentials chain of the cloud provider.
+// The tokens of the GitHub and GitLab CI jobs are used for their registries if no other credentials are found.
+func (o RegistryOptions) keychain() authn.Keychain {
+	switch {
+	case o.Token != "":
+		return staticKeychain{auth: &authn.Bearer{Token: o.Token}}
+	case o.Username != "":
+		return staticKeychain{auth: &authn.Basic{
+			Username: o.Username,
+			Password: o.Password,
+		}}
+	case o.Keychain == KeychainAnonymous:
+		return staticKeychain{auth: authn.Anonymous}
+	case o.Keychain == KeychainEnv:
+		return staticKeychain{auth: &authn.Basic{
+			Username: os.Getenv("REGISTRY_USERNAME"),
+			Password: os.Getenv("REGISTRY_PASSWORD"),
+		}}
+	case o.CredentialHelper != "" && o.DockerConfig != "":
+		return authn.NewMultiKeychain(helperKeychain(o.CredentialHelper), dockerConfigKeychain{dir: o.DockerConfig})
+	case o.CredentialHelper != "":
+		return authn.NewMultiKeychain(helperKeychain(o.CredentialHelper), authn.DefaultKeychain)
+	case o.DockerConfig != "":
+		return authn.NewMultiKeychain(ecrKeychain, google.Keychain, dockerConfigKeychain{dir: o.DockerConfig}, ciKeychain)
+	default:
+		return authn.NewMultiKeychain(ecrKeychain, google.Keychain, authn.DefaultKeychain, ciKeychain)
+	}
+}
+
+// transport returns the HTTP transport for the registry operations.
+// The proxy is taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY unless NoProxy is set.
+// The rate-limited requests are retried after the duration in the Retry-After header.
+// DisableCompression is left unset, so the responses are requested with gzip transfer encoding
+// and decompressed before the digest verification and the format detection.
+func (o RegistryOptions) transport() http.RoundTripper {
+	t := remote.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if o.NoProxy {
+		t.Proxy = nil
+	}
+	if o.Insecure || o.RootCAs != nil || len(o.Certificates) > 0 {
+		t.TLSClientConfig = &tls.Config{
+			RootCAs:            o.RootCAs,
+			Certificates:       o.Certificates,
+			InsecureSkipVerify: o.Insecure,
+		}
+	}
+	var rt http.RoundTripper = t
+	if len(o.InsecureRegistries) > 0 {
+		rt = insecureHostTransport{inner: t, hosts: o.InsecureRegistries}
+	}
+	return noRetryTransport{inner: retryAfterTransport{inner: rt, maxRetries: o.MaxRetries, delay: o.RetryDelay}}
+}
+
+// noRetryTransport returns the 408 and 5xx responses and the network errors as errors which aren't temporary.
+// go-containerregistry wraps the transport with its own retries of them, which can't be disabled,
+// so that Retry with MaxRetries and RetryDelay is the only retry layer.
+type noRetryTransport struct {
+	inner http.RoundTripper
+}
+
+func (t noRetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.inner.RoundTrip(req)
+	if err != nil {
+		return nil, permanentError{err: err}
+	}
+	if resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= http.StatusInternalServerError {
+		// The response isn't returned, so its body is closed here.
+		defer resp.Body.Close()
+		return nil, permanentError{err: transport.CheckError(resp)}
+	}
+	return resp, nil
+}
+
+// permanentError hides the Temporary method of the error, which the retries of go-containerregistry check.
+// The error is still found with errors.As.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// insecureHostTransport sends the requests to the listed hosts over plain HTTP.
+type insecureHostTransport struct {
+	inner http.RoundTripper
+	hosts []string
+}
+
+func (t insecureHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.inner.RoundTrip(req)
+	}
+	for _, host := range t.hosts {
+		if req.URL.Host == host {
+			req = req.Clone(req.Context())
+			req.URL.Scheme = "http"
+			break
+		}
+	}
+	return t.inner.RoundTrip(req)
+}
+
+// insecureRegistry reports whether the host is in InsecureRegistries.
+func (o RegistryOptions) insecureRegistry(host string) bool {
+	for _, h := range o.InsecureRegistries {
+		if h == host {
+			return true
+		}
+	}
+	return false
+}
+
+// noRetryBackoff makes a single attempt, since the operations are retried with Retry instead.
+var noRetryBackoff = remote.Backoff{Steps: 1}
+
+// RemoteOptions returns the options for the registry operations.
+// The retries of go-containerregistry are disabled so that MaxRetries and RetryDelay control them.
+func (o RegistryOptions) RemoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(o.keychain()),
+		remote.WithTransport(o.transport()),
+		remote.WithRetryBackoff(noRetryBackoff),
+	}
+}
@@ -0,0 +1,52 @@
+package referrer
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInsecureHostTransport(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	plain := httptest.NewServer(handler)
+	t.Cleanup(plain.Close)
+	// The TLS server has a self-signed certificate, which isn't trusted.
+	secure := httptest.NewUnstartedServer(handler)
+	secure.Config.ErrorLog = log.New(io.Discard, "", 0)
+	secure.StartTLS()
+	t.Cleanup(secure.Close)
+
+	plainHost := strings.TrimPrefix(plain.URL, "http://")
+	secureHost := strings.TrimPrefix(secure.URL, "https://")
+	client := &http.Client{Transport: RegistryOptions{InsecureRegistries: []string{plainHost}}.transport()}
+
+	t.Run("listed host over plain HTTP", func(t *testing.T) {
+		resp, err := client.Get("https://" + plainHost + "/v2/")
+		if err != nil {
+			t.Fatalf("GET error = %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+	})
+
+	t.Run("unlisted host with TLS verification", func(t *testing.T) {
+		resp, err := client.Get("https://" + secureHost + "/v2/")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatal("GET error = nil, want the certificate verification error")
+		}
+		var verr *tls.CertificateVerificationError
+		if !errors.As(err, &verr) {
+			t.Errorf("GET error = %s, want the certificate verification error", err)
+		}
+	})
+}
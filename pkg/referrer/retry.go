@@ -0,0 +1,64 @@
+package referrer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Default retry settings of the registry operations.
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryDelay = time.Second
+)
+
+// isRetryable reports whether the error is caused by a network failure or a server error.
+// Client errors such as 401, 403 and 404 are not retried, and 429 has already been retried by retryAfterTransport.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		return terr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the delay before the given retry attempt.
+// The delay grows exponentially from the base delay, with up to 50% of jitter added.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << attempt
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/2+1))
+}
+
+// retry calls f until it succeeds, it returns a non-retryable error, or the retries are exhausted.
+func retry(ctx context.Context, maxRetries int, delay time.Duration, f func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= maxRetries || !isRetryable(err) {
+			return err
+		}
+
+		d := backoff(delay, attempt)
+		log.Logger.Warnf("Retrying in %s (%d/%d): %s", d, attempt+1, maxRetries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package referrer
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+)
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		want       int32
+	}{
+		{
+			name:       "no retries",
+			maxRetries: 0,
+			want:       1,
+		},
+		{
+			name:       "two retries",
+			maxRetries: 2,
+			want:       3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			host := serveTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/v2/app/") {
+					attempts.Add(1)
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+
+			opts := RegistryOptions{MaxRetries: tt.maxRetries, RetryDelay: time.Millisecond}
+			if _, err := SubjectDigest(context.Background(), host+"/app:latest", opts); err == nil {
+				t.Fatal("SubjectDigest() error = nil, want 503")
+			}
+			// go-containerregistry doesn't retry on top of MaxRetries.
+			if got := attempts.Load(); got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferrersRetry(t *testing.T) {
+	var failed atomic.Bool
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true))
+	host := serveTestRegistry(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first request of the referrers API fails with a server error.
+		if strings.Contains(r.URL.Path, "/referrers/") && failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	subject := pushTestImage(t, host, "app")
+	opts := RegistryOptions{MaxRetries: 1, RetryDelay: time.Millisecond}
+
+	t.Run("ReferrersAPISupported", func(t *testing.T) {
+		failed.Store(false)
+		supported, err := ReferrersAPISupported(context.Background(), subject, opts)
+		if err != nil {
+			t.Fatalf("ReferrersAPISupported() error = %s", err)
+		}
+		if !supported {
+			t.Error("ReferrersAPISupported() = false, want true")
+		}
+	})
+
+	t.Run("FetchReferrers", func(t *testing.T) {
+		failed.Store(false)
+		if _, err := FetchReferrers(context.Background(), subject, opts); err != nil {
+			t.Fatalf("FetchReferrers() error = %s", err)
+		}
+	})
+}
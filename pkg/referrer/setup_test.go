@@ -0,0 +1,105 @@
+package referrer
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// newTestRegistry starts an in-memory registry and returns its host.
+// The loopback host is accessed over plain HTTP.
+func newTestRegistry(t *testing.T, opts ...registry.Option) string {
+	t.Helper()
+	opts = append([]registry.Option{registry.Logger(log.New(io.Discard, "", 0))}, opts...)
+	return serveTestRegistry(t, registry.New(opts...))
+}
+
+// serveTestRegistry serves the handler of a registry and returns its host.
+func serveTestRegistry(t *testing.T, h http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// pushTestImage pushes a random image to the repository as the latest tag and returns its digest reference.
+func pushTestImage(t *testing.T, host, repo string) name.Digest {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("error creating image: %s", err)
+	}
+
+	tag, err := name.NewTag(host + "/" + repo + ":latest")
+	if err != nil {
+		t.Fatalf("error parsing tag: %s", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("error pushing image: %s", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("error getting digest: %s", err)
+	}
+	return tag.Context().Digest(digest.String())
+}
+
+// testSubject is the subject of the fixtures in the tests which don't access the registry.
+func testSubject(t *testing.T) name.Digest {
+	t.Helper()
+	subject, err := name.NewDigest("ghcr.io/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536")
+	if err != nil {
+		t.Fatalf("error parsing subject: %s", err)
+	}
+	return subject
+}
+
+// loadFixture reads the file in testdata, replacing {{registry}} and {{digest}} with the ones of the subject.
+func loadFixture(t *testing.T, file string, subject name.Digest) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("error reading fixture: %s", err)
+	}
+
+	return []byte(strings.NewReplacer(
+		"{{registry}}", subject.RegistryStr(),
+		"{{digest}}", subject.DigestStr(),
+	).Replace(string(b)))
+}
+
+// fetchManifest returns the manifest pushed to the digest reference.
+func fetchManifest(t *testing.T, ref name.Digest) imageManifest {
+	t.Helper()
+	desc, err := remote.Get(ref)
+	if err != nil {
+		t.Fatalf("error fetching manifest: %s", err)
+	}
+
+	var manifest imageManifest
+	if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+		t.Fatalf("error parsing manifest: %s", err)
+	}
+	return manifest
+}
+
+// descriptorDigest returns the digest of the descriptor, or the zero hash if it is nil.
+func descriptorDigest(desc *v1.Descriptor) v1.Hash {
+	if desc == nil {
+		return v1.Hash{}
+	}
+	return desc.Digest
+}
@@ -0,0 +1,116 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// cosignBinary is used to sign the pushed referrer.
+// ref. https://github.com/sigstore/cosign
+const cosignBinary = "cosign"
+
+// CheckSignOptions returns an error if the registry options can't be forwarded to cosign,
+// so that it fails before the referrer is pushed.
+func CheckSignOptions(opts RegistryOptions) error {
+	if opts.RootCAs != nil || len(opts.Certificates) > 0 {
+		return fmt.Errorf("--cacert and --client-cert can't be used with --sign, which can't forward them to cosign")
+	}
+	return nil
+}
+
+// Sign signs the pushed referrer with cosign using the given key, which can be a key file or a KMS URI.
+// The signature is pushed as a referrer of the referrer with the OCI 1.1 referrers mode of cosign.
+// cosign is given the credentials resolved with the same keychain as the push in its registry flags,
+// so that nothing is written to disk. They need cosign v2.2.0 or later.
+func Sign(ctx context.Context, ref name.Digest, key string, opts RegistryOptions) error {
+	if key == "" {
+		return fmt.Errorf("a key or a KMS URI is required to sign the referrer")
+	}
+	if err := CheckSignOptions(opts); err != nil {
+		return err
+	}
+
+	args := []string{"sign", "--yes", "--key", key, "--registry-referrers-mode", "oci-1-1"}
+	credArgs, err := cosignCredentialArgs(ref, opts)
+	if err != nil {
+		return err
+	}
+	args = append(args, credArgs...)
+	if opts.Insecure || opts.insecureRegistry(ref.RegistryStr()) {
+		args = append(args, "--allow-insecure-registry", "--allow-http-registry")
+	}
+	args = append(args, ref.String())
+
+	cmd := exec.CommandContext(ctx, cosignBinary, args...)
+	// The OCI 1.1 referrers mode is experimental in cosign.
+	cmd.Env = append(cosignEnv(os.Environ(), opts.NoProxy), "COSIGN_EXPERIMENTAL=1")
+	if opts.DockerConfig != "" {
+		cmd.Env = append(cmd.Env, "DOCKER_CONFIG="+opts.DockerConfig)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	log.Logger.Infof("Signing referrer %s", ref.String())
+	if err := cmd.Run(); err != nil {
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return fmt.Errorf("error running %s: %w: %s", cosignBinary, err, msg)
+		}
+		return fmt.Errorf("error running %s: %w", cosignBinary, err)
+	}
+
+	return nil
+}
+
+// cosignCredentialArgs returns the registry flags of cosign with the credentials for the registry of the referrer.
+// No flags are returned when the keychain resolves to anonymous or to an identity token,
+// which cosign resolves with its own keychain from the Docker config.
+func cosignCredentialArgs(ref name.Digest, opts RegistryOptions) ([]string, error) {
+	auth, err := opts.keychain().Resolve(ref.Context())
+	if err != nil {
+		return nil, fmt.Errorf("error resolving credentials for %s: %w", ref.RegistryStr(), err)
+	}
+	cfg, err := auth.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("error resolving credentials for %s: %w", ref.RegistryStr(), err)
+	}
+
+	switch {
+	case cfg.RegistryToken != "":
+		return []string{"--registry-token", cfg.RegistryToken}, nil
+	case cfg.Username != "" || cfg.Password != "":
+		return []string{"--registry-username", cfg.Username, "--registry-password", cfg.Password}, nil
+	case cfg.Auth != "":
+		b, err := base64.StdEncoding.DecodeString(cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding credentials for %s: %w", ref.RegistryStr(), err)
+		}
+		username, password, _ := strings.Cut(string(b), ":")
+		return []string{"--registry-username", username, "--registry-password", password}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// cosignEnv returns the environment variables without the proxy variables if noProxy is set.
+func cosignEnv(environ []string, noProxy bool) []string {
+	var env []string
+	for _, kv := range environ {
+		k, _, _ := strings.Cut(kv, "=")
+		switch strings.ToUpper(k) {
+		case "HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY":
+			if noProxy {
+				continue
+			}
+		}
+		env = append(env, kv)
+	}
+	return env
+}
@@ -0,0 +1,89 @@
+package referrer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestCosignCredentialArgs(t *testing.T) {
+	dockerConfig := t.TempDir()
+	// "user:pass" in base64, as written by docker login.
+	config := `{"auths": {"registry.example.com": {"auth": "dXNlcjpwYXNz"}}}`
+	if err := os.WriteFile(filepath.Join(dockerConfig, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatalf("error writing docker config: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		opts RegistryOptions
+		want []string
+	}{
+		{
+			name: "username and password",
+			opts: RegistryOptions{Username: "user", Password: "pass"},
+			want: []string{"--registry-username", "user", "--registry-password", "pass"},
+		},
+		{
+			name: "token",
+			opts: RegistryOptions{Token: "token"},
+			want: []string{"--registry-token", "token"},
+		},
+		{
+			name: "docker config",
+			opts: RegistryOptions{DockerConfig: dockerConfig},
+			want: []string{"--registry-username", "user", "--registry-password", "pass"},
+		},
+		{
+			name: "anonymous",
+			opts: RegistryOptions{DockerConfig: t.TempDir()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cosignCredentialArgs(name.MustParseReference("registry.example.com/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536").(name.Digest), tt.opts)
+			if err != nil {
+				t.Fatalf("error getting credential args: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignNoCredentialsOnDisk(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake cosign is a shell script")
+	}
+
+	// The fake cosign records its arguments and DOCKER_CONFIG, which isn't replaced with a temporary config.
+	bin := t.TempDir()
+	out := filepath.Join(t.TempDir(), "cosign.out")
+	script := "#!/bin/sh\necho \"$DOCKER_CONFIG\" \"$@\" > " + out + "\n"
+	if err := os.WriteFile(filepath.Join(bin, cosignBinary), []byte(script), 0o755); err != nil {
+		t.Fatalf("error writing fake cosign: %s", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DOCKER_CONFIG", "")
+
+	ref := name.MustParseReference("registry.example.com/app@sha256:6c4fb75a80389e1dff7c313c6f74778c340cc051d5197044e6228ecc03265536").(name.Digest)
+	if err := Sign(context.Background(), ref, "cosign.key", RegistryOptions{Username: "user", Password: "pass"}); err != nil {
+		t.Fatalf("error signing: %s", err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("error reading cosign output: %s", err)
+	}
+	want := "sign --yes --key cosign.key --registry-referrers-mode oci-1-1 --registry-username user --registry-password pass " + ref.String()
+	if got := strings.TrimSpace(string(b)); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,195 @@
+package referrer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+const spdxDocumentID = "SPDXRef-DOCUMENT"
+
+// spdxDocument is the subset of an SPDX document needed to find the image.
+// sbom.Decode only supports SPDX 2.2 and rejects the fields added in SPDX 2.3,
+// so the documents are parsed into this version-agnostic form instead.
+type spdxDocument struct {
+	name          string
+	describes     []string
+	packages      []spdxPackage
+	relationships []spdxRelationship
+}
+
+type spdxPackage struct {
+	id   string
+	name string
+	refs []spdxExternalRef
+}
+
+type spdxExternalRef struct {
+	category string
+	locator  string
+}
+
+type spdxRelationship struct {
+	element      string
+	relationship string
+	related      string
+}
+
+func parseSpdxJSON(b []byte) (spdxDocument, error) {
+	var raw struct {
+		Name              string   `json:"name"`
+		DocumentDescribes []string `json:"documentDescribes"`
+		Packages          []struct {
+			SPDXID       string `json:"SPDXID"`
+			Name         string `json:"name"`
+			ExternalRefs []struct {
+				ReferenceCategory string `json:"referenceCategory"`
+				ReferenceLocator  string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+		Relationships []struct {
+			SpdxElementID      string `json:"spdxElementId"`
+			RelationshipType   string `json:"relationshipType"`
+			RelatedSpdxElement string `json:"relatedSpdxElement"`
+		} `json:"relationships"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return spdxDocument{}, fmt.Errorf("error decoding SPDX JSON: %w", err)
+	}
+
+	doc := spdxDocument{
+		name:      raw.Name,
+		describes: raw.DocumentDescribes,
+	}
+	for _, p := range raw.Packages {
+		pkg := spdxPackage{id: p.SPDXID, name: p.Name}
+		for _, ref := range p.ExternalRefs {
+			pkg.refs = append(pkg.refs, spdxExternalRef{category: ref.ReferenceCategory, locator: ref.ReferenceLocator})
+		}
+		doc.packages = append(doc.packages, pkg)
+	}
+	for _, rel := range raw.Relationships {
+		doc.relationships = append(doc.relationships, spdxRelationship{
+			element:      rel.SpdxElementID,
+			relationship: rel.RelationshipType,
+			related:      rel.RelatedSpdxElement,
+		})
+	}
+
+	return doc, nil
+}
+
+func parseSpdxTV(b []byte) (spdxDocument, error) {
+	var doc spdxDocument
+	var pkg *spdxPackage
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	for scanner.Scan() {
+		tag, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch tag {
+		case "DocumentName":
+			doc.name = value
+		case "PackageName":
+			doc.packages = append(doc.packages, spdxPackage{name: value})
+			pkg = &doc.packages[len(doc.packages)-1]
+		case "SPDXID":
+			if pkg != nil && pkg.id == "" {
+				pkg.id = value
+			}
+		case "ExternalRef":
+			// ExternalRef: <category> <type> <locator>
+			fields := strings.Fields(value)
+			if pkg != nil && len(fields) == 3 {
+				pkg.refs = append(pkg.refs, spdxExternalRef{category: fields[0], locator: fields[2]})
+			}
+		case "FileName", "SnippetSPDXID":
+			// The following tags belong to a file or a snippet, not to the package.
+			pkg = nil
+		case "Relationship":
+			// Relationship: <element> <type> <related element>
+			fields := strings.Fields(value)
+			if len(fields) == 3 {
+				doc.relationships = append(doc.relationships, spdxRelationship{
+					element:      fields[0],
+					relationship: fields[1],
+					related:      fields[2],
+				})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return spdxDocument{}, fmt.Errorf("error reading SPDX tag-value: %w", err)
+	}
+
+	return doc, nil
+}
+
+// rootPackages returns the candidates of the package describing the image.
+// The packages described by the document are returned first, followed by the package named after the document.
+func (d spdxDocument) rootPackages() []spdxPackage {
+	var pkgs []spdxPackage
+	seen := map[string]bool{}
+	add := func(id string) {
+		for _, pkg := range d.packages {
+			if pkg.id == id && !seen[id] {
+				pkgs = append(pkgs, pkg)
+				seen[id] = true
+			}
+		}
+	}
+
+	for _, id := range d.describes {
+		add(id)
+	}
+	for _, rel := range d.relationships {
+		switch {
+		case rel.relationship == "DESCRIBES" && rel.element == spdxDocumentID:
+			add(rel.related)
+		case rel.relationship == "DESCRIBED_BY" && rel.related == spdxDocumentID:
+			add(rel.element)
+		}
+	}
+
+	for _, pkg := range d.packages {
+		if pkg.name == d.name {
+			add(pkg.id)
+		}
+	}
+
+	return pkgs
+}
+
+func repoFromSpdx(doc spdxDocument, opts ...name.Option) (name.Reference, error) {
+	// A package may list several package manager references where only one is the purl of the image.
+	var errs []error
+	for _, pkg := range doc.rootPackages() {
+		for _, ref := range pkg.refs {
+			// SPDX 2.3 also allows PACKAGE_MANAGER.
+			if strings.ReplaceAll(ref.category, "_", "-") != "PACKAGE-MANAGER" {
+				continue
+			}
+			repo, err := repoFromPurl(ref.locator, opts...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", ref.locator, err))
+				continue
+			}
+			return repo, nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("no valid purl in the package manager references: %w", errors.Join(errs...))
+	}
+	return nil, fmt.Errorf("error getting repository from SPDX")
+}
@@ -0,0 +1,126 @@
+package referrer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aquasecurity/trivy/pkg/sbom"
+)
+
+func TestParseSpdxTV(t *testing.T) {
+	subject := testSubject(t)
+	b := loadFixture(t, "spdx.tv", subject)
+
+	format, err := sbom.DetectFormat(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("DetectFormat() error = %s", err)
+	}
+	if format != sbom.FormatSPDXTV {
+		t.Fatalf("DetectFormat() = %s, want %s", format, sbom.FormatSPDXTV)
+	}
+
+	doc, err := parseSpdxTV(b)
+	if err != nil {
+		t.Fatalf("parseSpdxTV() error = %s", err)
+	}
+	if want := "ghcr.io/app:latest"; doc.name != want {
+		t.Errorf("name = %q, want %q", doc.name, want)
+	}
+
+	repo, err := repoFromSpdx(doc)
+	if err != nil {
+		t.Fatalf("repoFromSpdx() error = %s", err)
+	}
+	if repo.String() != subject.String() {
+		t.Errorf("repoFromSpdx() = %s, want %s", repo, subject)
+	}
+}
+
+func TestRootPackages(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+	}{
+		{
+			name:    "DESCRIBES relationship",
+			fixture: "spdx-describes.json",
+		},
+		{
+			name:    "package named after the document",
+			fixture: "spdx-name.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := testSubject(t)
+			doc, err := parseSpdxJSON(loadFixture(t, tt.fixture, subject))
+			if err != nil {
+				t.Fatalf("parseSpdxJSON() error = %s", err)
+			}
+
+			pkgs := doc.rootPackages()
+			if len(pkgs) != 1 {
+				t.Fatalf("rootPackages() returned %d packages, want 1", len(pkgs))
+			}
+			if want := "SPDXRef-ContainerImage-1"; pkgs[0].id != want {
+				t.Errorf("root package = %s, want %s", pkgs[0].id, want)
+			}
+
+			repo, err := repoFromSpdx(doc)
+			if err != nil {
+				t.Fatalf("repoFromSpdx() error = %s", err)
+			}
+			if repo.String() != subject.String() {
+				t.Errorf("repoFromSpdx() = %s, want %s", repo, subject)
+			}
+		})
+	}
+}
+
+func TestRepoFromSpdx(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		wantErr string
+	}{
+		{
+			name:    "SPDX 2.3 with the PACKAGE_MANAGER category",
+			fixture: "spdx23.json",
+		},
+		{
+			name:    "several package manager references",
+			fixture: "spdx-refs.json",
+		},
+		{
+			name:    "no valid purl in the package manager references",
+			fixture: "spdx-refs-invalid.json",
+			wantErr: "no valid purl in the package manager references",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := testSubject(t)
+			doc, err := parseSpdxJSON(loadFixture(t, tt.fixture, subject))
+			if err != nil {
+				t.Fatalf("parseSpdxJSON() error = %s", err)
+			}
+
+			repo, err := repoFromSpdx(doc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("repoFromSpdx() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("repoFromSpdx() error = %s", err)
+			}
+			if repo.String() != subject.String() {
+				t.Errorf("repoFromSpdx() = %s, want %s", repo, subject)
+			}
+		})
+	}
+}
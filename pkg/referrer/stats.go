@@ -0,0 +1,63 @@
+package referrer
+
+import (
+	"strings"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/aquasecurity/trivy/pkg/sbom"
+)
+
+// SBOMStats summarizes the decoded SBOM for troubleshooting, such as when the subject isn't resolved.
+type SBOMStats struct {
+	Format sbom.Format
+	// Packages is the number of the components in CycloneDX, including the nested ones, or the packages in SPDX.
+	Packages int
+	// RootPurl is the purl of the component or the package describing the image, empty if it is missing.
+	RootPurl string
+}
+
+// reportStats calls Options.Stats if set.
+func (o Options) reportStats(stats SBOMStats) {
+	if o.Stats != nil {
+		o.Stats(stats)
+	}
+}
+
+func cycloneDXStats(format sbom.Format, bom *cdx.BOM) SBOMStats {
+	stats := SBOMStats{
+		Format:   format,
+		Packages: countComponents(bom.Components),
+	}
+	if bom.Metadata != nil && bom.Metadata.Component != nil {
+		stats.RootPurl = componentPurl(*bom.Metadata.Component)
+	}
+	return stats
+}
+
+func countComponents(components *[]cdx.Component) int {
+	if components == nil {
+		return 0
+	}
+
+	n := len(*components)
+	for _, c := range *components {
+		n += countComponents(c.Components)
+	}
+	return n
+}
+
+func spdxStats(format sbom.Format, doc spdxDocument) SBOMStats {
+	stats := SBOMStats{
+		Format:   format,
+		Packages: len(doc.packages),
+	}
+	for _, pkg := range doc.rootPackages() {
+		for _, ref := range pkg.refs {
+			if strings.HasPrefix(ref.locator, "pkg:") {
+				stats.RootPurl = ref.locator
+				return stats
+			}
+		}
+	}
+	return stats
+}
@@ -0,0 +1,311 @@
+package referrer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// SubjectDigest resolves the given image reference to a digest reference.
+// Tag references are resolved by fetching the manifest descriptor from the registry.
+func SubjectDigest(ctx context.Context, s string, opts RegistryOptions) (name.Digest, error) {
+	ref, err := name.ParseReference(s, opts.NameOptions()...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error parsing reference: %w", err)
+	}
+
+	return resolveDigest(ctx, ref, opts)
+}
+
+// resolveDigest resolves the tag reference to the digest it currently points to.
+func resolveDigest(ctx context.Context, ref name.Reference, opts RegistryOptions) (name.Digest, error) {
+	if digest, ok := ref.(name.Digest); ok {
+		return digest, nil
+	}
+
+	var desc *v1.Descriptor
+	err := opts.Retry(ctx, func() (err error) {
+		desc, err = remote.Head(ref, opts.RemoteOptions(ctx)...)
+		return err
+	})
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error getting descriptor: %w", authError(ref.Context().RegistryStr(), err))
+	}
+
+	digest := ref.Context().Digest(desc.Digest.String())
+	log.Logger.Infof("Subject %s is resolved to %s", ref.String(), digest.String())
+
+	return digest, nil
+}
+
+// withRepository replaces the repository of the subject with Options.Repository, keeping the digest.
+func (o Options) withRepository(repo name.Digest) (name.Digest, error) {
+	digest, err := name.NewDigest(fmt.Sprintf("%s@%s", o.Repository, repo.DigestStr()), o.NameOptions()...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("error parsing repository: %w", err)
+	}
+	log.Logger.Infof("Repository of the subject is replaced: %s", digest.String())
+
+	return digest, nil
+}
+
+// ResolveSubject resolves Options.Subject to the digest reference and the descriptor of the image to attach referrers to.
+func (o Options) ResolveSubject(ctx context.Context) (name.Digest, v1.Descriptor, error) {
+	repo, err := o.subjectRepo(ctx)
+	if err != nil {
+		return name.Digest{}, v1.Descriptor{}, err
+	}
+	return subjectDescriptor(ctx, repo, o)
+}
+
+// subjectDescriptor fetches the descriptor of the subject.
+// If a platform is specified and the subject is an image index,
+// the descriptor of the child manifest for the platform is returned instead.
+// With Options.RequireImage, an image index is rejected to avoid attaching a referrer for one platform to all of them.
+func subjectDescriptor(ctx context.Context, repo name.Digest, opts Options) (name.Digest, v1.Descriptor, error) {
+	repo, desc, err := fetchSubjectDescriptor(ctx, repo, opts)
+	if err != nil {
+		return name.Digest{}, v1.Descriptor{}, err
+	}
+	log.Logger.Infof("Subject %s has the media type %s", repo.String(), desc.MediaType)
+
+	if opts.RequireImage && desc.MediaType.IsIndex() {
+		return name.Digest{}, v1.Descriptor{}, fmt.Errorf("the subject %s is an image index, not an image: use --platform to select the image", repo.String())
+	}
+
+	return repo, desc, nil
+}
+
+func fetchSubjectDescriptor(ctx context.Context, repo name.Digest, opts Options) (name.Digest, v1.Descriptor, error) {
+	if opts.SkipHead {
+		digest, err := v1.NewHash(repo.DigestStr())
+		if err != nil {
+			return name.Digest{}, v1.Descriptor{}, fmt.Errorf("error parsing subject digest: %w", err)
+		}
+		// The subject can't be validated without fetching it.
+		return repo, v1.Descriptor{
+			MediaType: opts.SubjectMediaType,
+			Size:      opts.SubjectSize,
+			Digest:    digest,
+		}, nil
+	}
+
+	var desc *v1.Descriptor
+	err := opts.Retry(ctx, func() (err error) {
+		desc, err = remote.Head(repo, opts.RemoteOptions(ctx)...)
+		return err
+	})
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return name.Digest{}, v1.Descriptor{}, fmt.Errorf("the subject %s is not found in the registry: %w", repo.String(), err)
+	} else if err != nil {
+		return name.Digest{}, v1.Descriptor{}, fmt.Errorf("error getting descriptor: %w", authError(repo.RegistryStr(), err))
+	}
+
+	if err := validateSubject(ctx, repo, *desc, opts); err != nil {
+		return name.Digest{}, v1.Descriptor{}, err
+	}
+
+	if opts.Platform == nil {
+		return repo, *desc, nil
+	}
+
+	switch {
+	case desc.MediaType.IsIndex():
+		return platformDescriptor(ctx, repo, *opts.Platform, opts)
+	case desc.MediaType.IsImage():
+		if err := checkPlatform(ctx, repo, *opts.Platform, opts); err != nil {
+			return name.Digest{}, v1.Descriptor{}, err
+		}
+	}
+
+	return repo, *desc, nil
+}
+
+// mediaTypeArtifactManifest is the media type of the OCI artifact manifest, which is used only for referrers.
+const mediaTypeArtifactManifest = "application/vnd.oci.artifact.manifest.v1+json"
+
+// validateSubject returns an error if the subject isn't an image or an image index,
+// to avoid attaching a referrer to something unexpected.
+// Any manifest is accepted with Options.AllowAnySubject.
+// A subject which is itself a referrer is accepted only with Options.AllowChaining,
+// since the registries may not expose such chains well.
+func validateSubject(ctx context.Context, repo name.Digest, desc v1.Descriptor, opts Options) error {
+	if opts.AllowAnySubject && !desc.MediaType.IsImage() && !desc.MediaType.IsIndex() {
+		return validateArtifactSubject(ctx, repo, desc, opts)
+	}
+	if desc.MediaType == mediaTypeArtifactManifest {
+		return checkChaining(repo, opts)
+	}
+	if !desc.MediaType.IsImage() && !desc.MediaType.IsIndex() {
+		return fmt.Errorf("the subject %s has the media type %s, which is neither an image nor an image index: use --allow-any-subject to attach a referrer to it", repo.String(), desc.MediaType)
+	}
+	if desc.MediaType.IsIndex() {
+		return nil
+	}
+
+	var manifest *v1.Manifest
+	err := opts.Retry(ctx, func() error {
+		img, err := remote.Image(repo, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		manifest, err = img.Manifest()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error getting manifest: %w", authError(repo.RegistryStr(), err))
+	}
+	if manifest.Subject != nil {
+		return checkChaining(repo, opts)
+	}
+
+	return nil
+}
+
+// validateArtifactSubject accepts any manifest as the subject, such as an artifact pushed with ORAS,
+// checking only that it isn't itself a referrer.
+func validateArtifactSubject(ctx context.Context, repo name.Digest, desc v1.Descriptor, opts Options) error {
+	var manifest struct {
+		Subject *v1.Descriptor `json:"subject"`
+	}
+	err := opts.Retry(ctx, func() error {
+		d, err := remote.Get(repo, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(d.Manifest, &manifest)
+	})
+	if err != nil {
+		return fmt.Errorf("error getting manifest: %w", authError(repo.RegistryStr(), err))
+	}
+	if manifest.Subject != nil {
+		return checkChaining(repo, opts)
+	}
+
+	log.Logger.Infof("The subject %s is an artifact of the media type %s", repo.String(), desc.MediaType)
+	return nil
+}
+
+// checkChaining returns an error for the subject which is itself a referrer unless Options.AllowChaining is set.
+func checkChaining(repo name.Digest, opts Options) error {
+	if !opts.AllowChaining {
+		return fmt.Errorf("the subject %s is itself a referrer: use --allow-chaining to attach a referrer to it", repo.String())
+	}
+	log.Logger.Warnf("The subject %s is itself a referrer", repo.String())
+	return nil
+}
+
+// platformDescriptor returns the descriptor of the child manifest for the platform in the image index.
+func platformDescriptor(ctx context.Context, repo name.Digest, platform v1.Platform, opts Options) (name.Digest, v1.Descriptor, error) {
+	var index *v1.IndexManifest
+	err := opts.Retry(ctx, func() error {
+		idx, err := remote.Index(repo, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		index, err = idx.IndexManifest()
+		return err
+	})
+	if err != nil {
+		return name.Digest{}, v1.Descriptor{}, fmt.Errorf("error getting index manifest: %w", err)
+	}
+
+	for _, m := range index.Manifests {
+		if m.Platform == nil || !m.Platform.Satisfies(platform) {
+			continue
+		}
+
+		child := repo.Context().Digest(m.Digest.String())
+		log.Logger.Infof("Platform %s is selected from the index: %s", platform.String(), child.String())
+
+		return child, v1.Descriptor{
+			MediaType: m.MediaType,
+			Size:      m.Size,
+			Digest:    m.Digest,
+		}, nil
+	}
+
+	return name.Digest{}, v1.Descriptor{}, fmt.Errorf("no manifest for platform %s found in %s", platform.String(), repo.String())
+}
+
+// WithSubject returns a copy of the referrer attached to another subject, sharing the same content.
+func (r *Referrer) WithSubject(repo name.Digest, desc v1.Descriptor) *Referrer {
+	ref := *r
+	ref.Annotations = make(map[string]string, len(r.Annotations))
+	for k, v := range r.Annotations {
+		ref.Annotations[k] = v
+	}
+	ref.TargetRepo = repo
+	ref.TargetDesc = desc
+	return &ref
+}
+
+// PlatformReferrers returns the copies of the referrer attached to each platform-specific manifest of the subject image index,
+// sharing the same content. The manifests without a platform or for the unknown platform, such as the attestations of buildx, are skipped.
+func (r *Referrer) PlatformReferrers(ctx context.Context, opts RegistryOptions) ([]*Referrer, error) {
+	if !r.TargetDesc.MediaType.IsIndex() {
+		return nil, fmt.Errorf("the subject %s is not an image index", r.TargetRepo.String())
+	}
+
+	var index *v1.IndexManifest
+	err := opts.Retry(ctx, func() error {
+		idx, err := remote.Index(r.TargetRepo, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		index, err = idx.IndexManifest()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting index manifest: %w", err)
+	}
+
+	var refs []*Referrer
+	for _, m := range index.Manifests {
+		if m.Platform == nil || m.Platform.OS == "unknown" {
+			continue
+		}
+
+		refs = append(refs, r.WithSubject(r.TargetRepo.Context().Digest(m.Digest.String()), v1.Descriptor{
+			MediaType: m.MediaType,
+			Size:      m.Size,
+			Digest:    m.Digest,
+		}))
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("no platform-specific manifest found in %s", r.TargetRepo.String())
+	}
+
+	return refs, nil
+}
+
+// checkPlatform returns an error if the image isn't for the platform.
+func checkPlatform(ctx context.Context, repo name.Digest, platform v1.Platform, opts Options) error {
+	var cfg *v1.ConfigFile
+	err := opts.Retry(ctx, func() error {
+		img, err := remote.Image(repo, opts.RemoteOptions(ctx)...)
+		if err != nil {
+			return err
+		}
+		cfg, err = img.ConfigFile()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error getting config file: %w", err)
+	}
+
+	if p := cfg.Platform(); p == nil || !p.Satisfies(platform) {
+		return fmt.Errorf("the subject %s is not for platform %s", repo.String(), platform.String())
+	}
+
+	return nil
+}
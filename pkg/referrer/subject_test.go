@@ -0,0 +1,24 @@
+package referrer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestBuildReferrerTagPurl(t *testing.T) {
+	host := newTestRegistry(t)
+	subject := pushTestImage(t, host, "app")
+
+	// The purl has the latest tag as the version, which is resolved to the digest of the image with HEAD.
+	ref, err := BuildReferrer(context.Background(), bytes.NewReader(loadFixture(t, "cyclonedx-tag.json", subject)), Options{})
+	if err != nil {
+		t.Fatalf("BuildReferrer() error = %s", err)
+	}
+	if ref.TargetRepo.String() != subject.String() {
+		t.Errorf("subject = %s, want %s", ref.TargetRepo, subject)
+	}
+	if ref.TargetDesc.Digest.String() != subject.DigestStr() {
+		t.Errorf("subject descriptor digest = %s, want %s", ref.TargetDesc.Digest, subject.DigestStr())
+	}
+}
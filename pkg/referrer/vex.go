@@ -0,0 +1,150 @@
+package referrer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cdx "github.com/CycloneDX/cyclonedx-go"
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/package-url/packageurl-go"
+)
+
+const (
+	// ref. https://github.com/openvex/spec/blob/main/OPENVEX-SPEC.md
+	MediaKeyOpenVEX = "application/vnd.openvex+json"
+
+	openVEXContextPrefix = "https://openvex.dev/ns"
+)
+
+var errFailedVEXDetection = fmt.Errorf("failed to detect OpenVEX")
+
+// openVEXProduct is the product of the statement, which is either an object with @id (v0.2.0 or later) or a string.
+type openVEXProduct struct {
+	ID string `json:"@id"`
+}
+
+func (p *openVEXProduct) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.ID); err == nil {
+		return nil
+	}
+
+	var product struct {
+		ID string `json:"@id"`
+	}
+	if err := json.Unmarshal(b, &product); err != nil {
+		return err
+	}
+	p.ID = product.ID
+
+	return nil
+}
+
+type openVEXDocument struct {
+	Context    string `json:"@context"`
+	Statements []struct {
+		Products []openVEXProduct `json:"products"`
+	} `json:"statements"`
+}
+
+func decodeOpenVEX(b []byte, p inputProbe) (openVEXDocument, error) {
+	if !p.isOpenVEX() {
+		return openVEXDocument{}, errFailedVEXDetection
+	}
+
+	var doc openVEXDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return openVEXDocument{}, errFailedVEXDetection
+	}
+	if !strings.HasPrefix(doc.Context, openVEXContextPrefix) {
+		return openVEXDocument{}, errFailedVEXDetection
+	}
+
+	return doc, nil
+}
+
+// repoFromOpenVEX returns the image the statements are about.
+// It fails if the statements are about multiple images rather than attaching the VEX document to a wrong image.
+func repoFromOpenVEX(doc openVEXDocument, opts ...name.Option) (name.Reference, error) {
+	var repo name.Reference
+	for _, st := range doc.Statements {
+		for _, p := range st.Products {
+			// The products other than images, such as the packages in the image, are skipped.
+			if pu, err := packageurl.FromString(p.ID); err != nil || !isImagePurlType(pu.Type) {
+				continue
+			}
+
+			other, err := repoFromPurl(p.ID, opts...)
+			if err != nil {
+				return nil, err
+			}
+			if repo == nil {
+				repo = other
+			} else if other.String() != repo.String() {
+				return nil, fmt.Errorf("the VEX document covers multiple images (%s and %s); use --subject to specify the image", repo.String(), other.String())
+			}
+		}
+	}
+
+	if repo == nil {
+		return nil, fmt.Errorf("no product identified by an OCI or Docker purl found in the VEX document; use --subject to specify the image")
+	}
+
+	return repo, nil
+}
+
+// isCycloneDXVEX reports whether the BOM is a VEX document, which has vulnerabilities but no components.
+func isCycloneDXVEX(bom *cdx.BOM) bool {
+	return bom.Vulnerabilities != nil && len(*bom.Vulnerabilities) > 0 &&
+		(bom.Components == nil || len(*bom.Components) == 0)
+}
+
+func tryReferrerFromVEX(ctx context.Context, b []byte, p inputProbe, opts Options) (*Referrer, error) {
+	doc, err := decodeOpenVEX(b, p)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Logger.Infof("OpenVEX document detected")
+
+	var repo name.Digest
+	if opts.hasSubject() {
+		repo, err = opts.subjectRepo(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		subject, err := repoFromOpenVEX(doc, opts.NameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("error getting repository from OpenVEX: %w", err)
+		}
+		repo, err = resolveDigest(ctx, subject, opts.RegistryOptions)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving subject: %w", err)
+		}
+		if opts.Repository != "" {
+			repo, err = opts.withRepository(repo)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	repo, targetDesc, err := subjectDescriptor(ctx, repo, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting subject descriptor: %w", err)
+	}
+
+	return &Referrer{
+		Annotations: map[string]string{
+			AnnotationKeyDescription: "OpenVEX",
+		},
+		MediaType:  ctypes.MediaType(MediaKeyOpenVEX),
+		Bytes:      b,
+		TargetRepo: repo,
+		TargetDesc: targetDesc,
+	}, nil
+}
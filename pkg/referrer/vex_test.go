@@ -0,0 +1,67 @@
+package referrer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRepoFromOpenVEX(t *testing.T) {
+	subject := testSubject(t)
+	ociPurl := fmt.Sprintf("pkg:oci/app@%s?repository_url=%s/app", subject.DigestStr(), subject.RegistryStr())
+	dockerPurl := fmt.Sprintf("pkg:docker/app@%s?repository_url=%s/app", subject.DigestStr(), subject.RegistryStr())
+	otherPurl := fmt.Sprintf("pkg:oci/other@%s?repository_url=%s/other", subject.DigestStr(), subject.RegistryStr())
+
+	tests := []struct {
+		name     string
+		products []string
+		wantErr  string
+	}{
+		{
+			name:     "OCI purl",
+			products: []string{ociPurl},
+		},
+		{
+			name:     "Docker purl",
+			products: []string{dockerPurl},
+		},
+		{
+			name:     "image and its package",
+			products: []string{"pkg:golang/golang.org/x/net@v0.7.0", dockerPurl},
+		},
+		{
+			name:     "no image",
+			products: []string{"pkg:golang/golang.org/x/net@v0.7.0"},
+			wantErr:  "no product identified by an OCI or Docker purl",
+		},
+		{
+			name:     "multiple images",
+			products: []string{dockerPurl, otherPurl},
+			wantErr:  "covers multiple images",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := []byte(fmt.Sprintf(`{"@context": "https://openvex.dev/ns/v0.2.0", "statements": [{"products": ["%s"]}]}`, strings.Join(tt.products, `", "`)))
+			doc, err := decodeOpenVEX(b, probeInput(b))
+			if err != nil {
+				t.Fatalf("decodeOpenVEX() error = %s", err)
+			}
+
+			repo, err := repoFromOpenVEX(doc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("repoFromOpenVEX() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("repoFromOpenVEX() error = %s", err)
+			}
+			if repo.String() != subject.String() {
+				t.Errorf("repoFromOpenVEX() = %s, want %s", repo, subject)
+			}
+		})
+	}
+}
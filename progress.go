@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// progressPrinter returns a function printing the upload progress to w in a single line.
+func progressPrinter(w io.Writer) func(v1.Update) {
+	var done bool
+	return func(update v1.Update) {
+		if done {
+			return
+		}
+
+		// The completed bytes may exceed the total reported by go-containerregistry.
+		complete := update.Complete
+		if complete >= update.Total {
+			complete = update.Total
+			done = true
+		}
+
+		fmt.Fprintf(w, "\rUploading: %d / %d bytes", complete, update.Total)
+		if done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// statsPrinter returns a function printing the summary of the decoded SBOM to w.
+func statsPrinter(w io.Writer) func(referrer.SBOMStats) {
+	return func(stats referrer.SBOMStats) {
+		rootPurl := stats.RootPurl
+		if rootPurl == "" {
+			rootPurl = "(none)"
+		}
+
+		fmt.Fprintf(w, "Format: %s\n", stats.Format)
+		fmt.Fprintf(w, "Packages: %d\n", stats.Packages)
+		fmt.Fprintf(w, "Root purl: %s\n", rootPurl)
+	}
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/aquasecurity/trivy/pkg/log"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ctypes "github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+const defaultConcurrency = 4
+
+type putOptions struct {
+	referrer.Options
+	dryRun       bool
+	description  string
+	note         string
+	noteKey      string
+	artifactType string
+	// allowMediaTypeMismatch allows the artifact type of another format than the detected one.
+	allowMediaTypeMismatch bool
+	compress               bool
+	manifestStyle          string
+	emptyConfig            bool
+	export                 string
+	manifestMediaType      string
+	layerTitle             string
+	reproducible           bool
+	tag                    string
+	ndjson                 bool
+	allPlatforms           bool
+	alsoTo                 []string
+	fromImage              string
+	signKey                string
+	concurrency            int
+	force                  bool
+	replaceExisting        bool
+	quiet                  bool
+	annotations            map[string]string
+	output                 string
+	outputManifest         string
+}
+
+// knownMediaTypes are the media types of the formats detected from the input.
+var knownMediaTypes = []ctypes.MediaType{
+	referrer.MediaKeyCycloneDX,
+	referrer.MediaKeyCycloneDXXML,
+	referrer.MediaKeySPDX,
+	referrer.MediaKeySPDXTV,
+	referrer.MediaKeyInToto,
+	referrer.MediaKeyOpenVEX,
+}
+
+// checkArtifactType returns an error if the artifact type is the media type of a format other than the detected one,
+// which would mislead the consumers. Custom artifact types are allowed.
+func checkArtifactType(ref *referrer.Referrer, allowMismatch bool) error {
+	if ref.ArtifactType == ref.MediaType {
+		return nil
+	}
+
+	for _, mt := range knownMediaTypes {
+		if ref.ArtifactType != mt {
+			continue
+		}
+		if allowMismatch {
+			log.Logger.Warnf("The artifact type %s doesn't match the detected media type %s", ref.ArtifactType, ref.MediaType)
+			return nil
+		}
+		return fmt.Errorf("the artifact type %s doesn't match the detected media type %s: use --allow-media-type-mismatch to push it anyway", ref.ArtifactType, ref.MediaType)
+	}
+
+	return nil
+}
+
+// replaceExistingReferrers deletes the other referrers of the subject with the same artifact type as the pushed one,
+// so that the subject keeps exactly one referrer of each type.
+func replaceExistingReferrers(ctx context.Context, ref *referrer.Referrer, tag name.Digest, opts referrer.RegistryOptions) error {
+	artifactType := artifactTypeOf(ref)
+
+	index, err := referrer.FetchReferrers(ctx, ref.TargetRepo, opts)
+	if err != nil {
+		return fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	var old []v1.Descriptor
+	for _, desc := range index.Manifests {
+		// Never delete the referrer just pushed.
+		if desc.ArtifactType == string(artifactType) && desc.Digest.String() != tag.DigestStr() {
+			old = append(old, desc)
+		}
+	}
+	if len(old) == 0 {
+		return nil
+	}
+
+	if err := deleteDescriptors(ctx, ref.TargetRepo, old, opts); err != nil {
+		return fmt.Errorf("error replacing existing referrers: %w", err)
+	}
+	log.Logger.Infof("Replaced %d existing referrers of %s", len(old), artifactType)
+
+	return nil
+}
+
+// writeManifest saves the manifest of the referrer image to the file.
+func writeManifest(path string, img v1.Image) error {
+	manifest, err := img.RawManifest()
+	if err != nil {
+		return fmt.Errorf("error getting manifest: %w", err)
+	}
+
+	if err := os.WriteFile(path, manifest, 0o644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+
+	return nil
+}
+
+// referrerExists returns the referrer attached to the subject with the same content and artifact type as img, if any.
+// The manifest digest isn't compared alone, since the created timestamp annotation makes it differ on every run.
+func referrerExists(ctx context.Context, subject, tag name.Digest, img v1.Image, artifactType string, opts referrer.RegistryOptions) (name.Digest, bool, error) {
+	index, err := referrer.FetchReferrers(ctx, subject, opts)
+	if err != nil {
+		return name.Digest{}, false, fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	layerDigest, err := firstLayerDigest(img)
+	if err != nil {
+		return name.Digest{}, false, err
+	}
+
+	for _, desc := range index.Manifests {
+		existing := subject.Context().Digest(desc.Digest.String())
+		if desc.Digest.String() == tag.DigestStr() {
+			return existing, true, nil
+		}
+		if desc.ArtifactType != artifactType {
+			continue
+		}
+
+		var existingImg v1.Image
+		err := opts.Retry(ctx, func() (err error) {
+			existingImg, err = remote.Image(existing, opts.RemoteOptions(ctx)...)
+			return err
+		})
+		if err != nil {
+			return name.Digest{}, false, fmt.Errorf("error fetching referrer %s: %w", existing.String(), err)
+		}
+		existingDigest, err := firstLayerDigest(existingImg)
+		if err != nil {
+			return name.Digest{}, false, err
+		}
+		if existingDigest == layerDigest {
+			return existing, true, nil
+		}
+	}
+
+	return name.Digest{}, false, nil
+}
+
+// artifactTypeOf returns the artifact type of the referrer, which defaults to its media type.
+func artifactTypeOf(ref *referrer.Referrer) ctypes.MediaType {
+	if ref.ArtifactType != "" {
+		return ref.ArtifactType
+	}
+	return ref.MediaType
+}
+
+// firstLayerDigest returns the digest of the layer holding the content of the referrer.
+func firstLayerDigest(img v1.Image) (v1.Hash, error) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return v1.Hash{}, fmt.Errorf("error getting manifest: %w", err)
+	}
+	if len(manifest.Layers) == 0 {
+		return v1.Hash{}, nil
+	}
+	return manifest.Layers[0].Digest, nil
+}
+
+func putReferrer(ctx context.Context, r io.Reader, w io.Writer, opts putOptions) error {
+	ref, err := referrer.BuildReferrer(ctx, r, opts.Options)
+	if err != nil {
+		return fmt.Errorf("error getting referrer: %w", &inputError{err: err})
+	}
+
+	if opts.description != "" {
+		ref.Annotations[referrer.AnnotationKeyDescription] = opts.description
+	}
+	if opts.note != "" {
+		ref.Annotations[opts.noteKey] = opts.note
+	}
+	for k, v := range opts.annotations {
+		ref.Annotations[k] = v
+	}
+	ref.Compress = opts.compress
+	ref.ManifestStyle = opts.manifestStyle
+	ref.ManifestMediaType = ctypes.MediaType(opts.manifestMediaType)
+	ref.EmptyConfig = opts.emptyConfig
+	ref.Title = opts.layerTitle
+	if opts.artifactType != "" {
+		ref.ArtifactType = ctypes.MediaType(opts.artifactType)
+		if err := checkArtifactType(ref, opts.allowMediaTypeMismatch); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.alsoTo) > 0 {
+		return pushReferrerToRepositories(ctx, ref, w, opts)
+	}
+	if !opts.allPlatforms {
+		return pushReferrer(ctx, ref, w, opts)
+	}
+
+	refs, err := ref.PlatformReferrers(ctx, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error getting platforms: %w", err)
+	}
+	for _, ref := range refs {
+		if err := pushReferrer(ctx, ref, w, opts); err != nil {
+			return err
+		}
+	}
+	log.Logger.Infof("Put the referrer for %d platforms", len(refs))
+
+	return nil
+}
+
+// pushReferrerToRepositories pushes the referrer to the subject and to the same digest in each of opts.alsoTo,
+// such as the mirrors of the image. A failure doesn't abort the others.
+func pushReferrerToRepositories(ctx context.Context, ref *referrer.Referrer, w io.Writer, opts putOptions) error {
+	names := []string{"to " + ref.TargetRepo.Context().Name()}
+	errs := []error{pushReferrer(ctx, ref, w, opts)}
+	for _, repo := range opts.alsoTo {
+		names = append(names, "to "+repo)
+		errs = append(errs, func() error {
+			subjectOpts := opts.Options
+			subjectOpts.Subject = fmt.Sprintf("%s@%s", repo, ref.TargetRepo.DigestStr())
+			// --repository and --subject-digest take precedence over Subject, and would resolve the main subject again.
+			subjectOpts.Repository = ""
+			subjectOpts.SubjectDigest = ""
+			subject, desc, err := subjectOpts.ResolveSubject(ctx)
+			if err != nil {
+				return fmt.Errorf("error resolving subject in %s: %w", repo, err)
+			}
+			return pushReferrer(ctx, ref.WithSubject(subject, desc), w, opts)
+		}())
+	}
+
+	return summarize("put", names, errs)
+}
+
+// pushReferrer pushes the built referrer, or prints its manifest with --dry-run.
+func pushReferrer(ctx context.Context, ref *referrer.Referrer, w io.Writer, opts putOptions) error {
+	img, err := ref.Image()
+	if err != nil {
+		return fmt.Errorf("error getting image: %w", err)
+	}
+
+	tag, err := ref.Tag(img)
+	if err != nil {
+		return fmt.Errorf("error getting tag: %w", err)
+	}
+
+	var extraTag name.Tag
+	if opts.tag != "" {
+		extraTag, err = name.NewTag(fmt.Sprintf("%s:%s", ref.TargetRepo.Context().Name(), opts.tag), opts.NameOptions()...)
+		if err != nil {
+			return fmt.Errorf("invalid tag %s: %w", opts.tag, err)
+		}
+	}
+
+	if opts.dryRun {
+		manifest, err := img.RawManifest()
+		if err != nil {
+			return fmt.Errorf("error getting manifest: %w", err)
+		}
+
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, manifest, "", "  "); err != nil {
+			return fmt.Errorf("error formatting manifest: %w", err)
+		}
+
+		log.Logger.Infof("Dry run: skipping push of referrer to %s", tag.String())
+		fmt.Fprintln(w, tag.String())
+		fmt.Fprintln(w, buf.String())
+
+		return nil
+	}
+
+	if opts.export != "" {
+		if err := exportLayout(opts.export, tag, img); err != nil {
+			return err
+		}
+		log.Logger.Infof("Exported referrer %s to %s, push it with `trivy referrer push %s`", tag.String(), opts.export, opts.export)
+		if !opts.quiet {
+			fmt.Fprintf(w, "exported: %s\n", tag.String())
+		}
+		return nil
+	}
+
+	var existing name.Digest
+	var exists bool
+	if !opts.force {
+		existing, exists, err = referrerExists(ctx, ref.TargetRepo, tag, img, string(artifactTypeOf(ref)), opts.RegistryOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if exists {
+		log.Logger.Infof("Referrer %s with the same content is already present, skipping the push. Use --force to push it anyway", existing.String())
+	} else {
+		if err := referrer.Push(ctx, ref, opts.Options); err != nil {
+			return err
+		}
+
+		if opts.signKey != "" {
+			if err := referrer.Sign(ctx, tag, opts.signKey, opts.RegistryOptions); err != nil {
+				return fmt.Errorf("error signing referrer: %w", err)
+			}
+		}
+	}
+
+	if opts.replaceExisting {
+		// The referrer already present is kept, since the one just built wasn't pushed.
+		keep := tag
+		if exists {
+			keep = existing
+		}
+		if err := replaceExistingReferrers(ctx, ref, keep, opts.RegistryOptions); err != nil {
+			return err
+		}
+	}
+
+	if opts.outputManifest != "" {
+		if err := writeManifest(opts.outputManifest, img); err != nil {
+			return err
+		}
+	}
+
+	if opts.tag != "" {
+		// Pushing img under the tag would add a duplicate referrer of the subject next to the existing one.
+		if exists {
+			err = referrer.TagExisting(ctx, extraTag, existing, opts.RegistryOptions)
+		} else {
+			err = referrer.PushTag(ctx, extraTag, img, opts.RegistryOptions)
+		}
+		if err != nil {
+			return err
+		}
+		log.Logger.Infof("Tagged referrer as %s", extraTag.String())
+	}
+
+	// The referrer already present is reported instead of the one never pushed.
+	reported, status := tag, "pushed"
+	if exists {
+		reported, status = existing, "exists"
+	}
+	if opts.output != "" {
+		result := putResult{
+			Reference:   reported.String(),
+			Subject:     ref.TargetRepo.Context().Digest(ref.TargetDesc.Digest.String()).String(),
+			MediaType:   string(ref.MediaType),
+			Annotations: ref.Annotations,
+			Skipped:     exists,
+		}
+		if err := writePutResult(w, result, opts.output); err != nil {
+			return fmt.Errorf("error writing result: %w", err)
+		}
+	} else if !opts.quiet {
+		// A predictable line for scripts capturing what was pushed.
+		fmt.Fprintf(w, "%s: %s\n", status, reported.String())
+	}
+
+	return nil
+}
+
+func putReferrerFromPath(ctx context.Context, path string, w io.Writer, opts putOptions) error {
+	rc, err := openInput(ctx, path, opts.MaxSize)
+	if err != nil {
+		return fmt.Errorf("error opening input: %w", &inputError{err: err})
+	}
+	defer rc.Close()
+
+	// The file name may differ between runs, such as a temporary file.
+	if opts.layerTitle == "" && !opts.reproducible {
+		opts.layerTitle = inputTitle(path)
+	}
+
+	if opts.ndjson {
+		return putReferrersNDJSON(ctx, rc, w, opts)
+	}
+	return putReferrer(ctx, rc, w, opts)
+}
+
+// sbomMediaTypes are the media types of the SBOM referrers read with --from-image.
+var sbomMediaTypes = []ctypes.MediaType{
+	referrer.MediaKeyCycloneDX,
+	referrer.MediaKeyCycloneDXXML,
+	referrer.MediaKeySPDX,
+	referrer.MediaKeySPDXTV,
+}
+
+// findSBOMReferrer returns the first SBOM referrer attached to the subject.
+func findSBOMReferrer(ctx context.Context, subject name.Digest, opts referrer.RegistryOptions) (v1.Descriptor, error) {
+	index, err := referrer.FetchReferrers(ctx, subject, opts)
+	if err != nil {
+		return v1.Descriptor{}, fmt.Errorf("error fetching referrers: %w", err)
+	}
+
+	for _, desc := range index.Manifests {
+		for _, mt := range sbomMediaTypes {
+			if desc.ArtifactType == string(mt) {
+				return desc, nil
+			}
+		}
+	}
+
+	return v1.Descriptor{}, fmt.Errorf("%w for %s with an SBOM media type", errNoReferrer, subject.String())
+}
+
+// putReferrerFromImage puts the SBOM referrer attached to the image as the input, such as to move it to another registry.
+func putReferrerFromImage(ctx context.Context, image string, w io.Writer, opts putOptions) error {
+	digest, err := referrer.SubjectDigest(ctx, image, opts.RegistryOptions)
+	if err != nil {
+		return fmt.Errorf("error resolving image: %w", err)
+	}
+
+	desc, err := findSBOMReferrer(ctx, digest, opts.RegistryOptions)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeReferrer(ctx, digest, desc, &buf, opts.RegistryOptions); err != nil {
+		return err
+	}
+
+	return putReferrer(ctx, &buf, w, opts)
+}
+
+// putReferrers puts the referrers read from the given paths with up to opts.concurrency workers.
+// A failure doesn't abort the others, and the results are reported at the end.
+func putReferrers(ctx context.Context, paths []string, w io.Writer, opts putOptions) error {
+	concurrency := opts.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Buffer the output so that the outputs of the referrers don't interleave.
+			var buf bytes.Buffer
+			errs[i] = putReferrerFromPath(ctx, path, &buf, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if _, err := w.Write(buf.Bytes()); err != nil && errs[i] == nil {
+				errs[i] = fmt.Errorf("error writing output: %w", err)
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = "from " + path
+	}
+	return summarize("put", names, errs)
+}
+
+// summarize logs the result of each input or destination, such as "from sbom.json", of the action such as "put",
+// and returns an error if any of them failed.
+func summarize(action string, names []string, errs []error) error {
+	done := map[string]string{"put": "Put", "copy": "Copied"}
+	var failed int
+	var firstErr error
+	for i, name := range names {
+		if errs[i] != nil {
+			log.Logger.Errorf("Failed to %s referrer %s: %s", action, name, errs[i])
+			failed++
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+		} else {
+			log.Logger.Infof("%s referrer %s", done[action], name)
+		}
+	}
+	log.Logger.Infof("%d succeeded, %d failed", len(names)-failed, failed)
+
+	if failed > 0 {
+		// Wrap the first error so that the exit code reflects its class.
+		return fmt.Errorf("failed to %s %d of %d referrers, first error: %w", action, failed, len(names), firstErr)
+	}
+
+	return nil
+}
+
+// putReferrersNDJSON puts each line of r as a referrer, for the newline-delimited JSON documents.
+// Empty lines are skipped, and a failure doesn't abort the others.
+func putReferrersNDJSON(ctx context.Context, r io.Reader, w io.Writer, opts putOptions) error {
+	var names []string
+	var errs []error
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading: %w", &inputError{err: err})
+		}
+
+		if len(bytes.TrimSpace(b)) > 0 {
+			names = append(names, fmt.Sprintf("from document at line %d", line))
+			errs = append(errs, putReferrer(ctx, bytes.NewReader(b), w, opts))
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if len(names) == 0 {
+		return &inputError{err: errors.New("no document found in the input")}
+	}
+
+	return summarize("put", names, errs)
+}
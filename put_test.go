@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+func TestPutReplaceExistingSkipped(t *testing.T) {
+	ctx := context.Background()
+	host := newTestRegistry(t)
+	subject := pushTestImage(t, host, "app")
+	input := loadFixture(t, "cyclonedx.json", subject)
+
+	if err := putReferrer(ctx, strings.NewReader(input), io.Discard, putOptions{}); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+	index, err := referrer.FetchReferrers(ctx, subject, referrer.RegistryOptions{})
+	if err != nil {
+		t.Fatalf("error fetching referrers: %s", err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Fatalf("got %d referrers, want 1", len(index.Manifests))
+	}
+	first := index.Manifests[0].Digest
+
+	// The annotation changes the manifest digest but not the content, so the push is skipped.
+	opts := putOptions{
+		replaceExisting: true,
+		annotations:     map[string]string{"org.example.run": "2"},
+	}
+	if err := putReferrer(ctx, strings.NewReader(input), io.Discard, opts); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+
+	index, err = referrer.FetchReferrers(ctx, subject, referrer.RegistryOptions{})
+	if err != nil {
+		t.Fatalf("error fetching referrers: %s", err)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != first {
+		t.Errorf("got referrers %v, want only %s", index.Manifests, first)
+	}
+}
+
+func TestPutTagSkipped(t *testing.T) {
+	ctx := context.Background()
+	host := newTestRegistry(t)
+	subject := pushTestImage(t, host, "app")
+	input := loadFixture(t, "cyclonedx.json", subject)
+
+	if err := putReferrer(ctx, strings.NewReader(input), io.Discard, putOptions{}); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+
+	opts := putOptions{
+		tag:         "sbom",
+		annotations: map[string]string{"org.example.run": "2"},
+	}
+	if err := putReferrer(ctx, strings.NewReader(input), io.Discard, opts); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+
+	index, err := referrer.FetchReferrers(ctx, subject, referrer.RegistryOptions{})
+	if err != nil {
+		t.Fatalf("error fetching referrers: %s", err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Fatalf("got %d referrers, want 1", len(index.Manifests))
+	}
+
+	desc, err := remote.Head(subject.Context().Tag("sbom"))
+	if err != nil {
+		t.Fatalf("error fetching tag: %s", err)
+	}
+	if desc.Digest != index.Manifests[0].Digest {
+		t.Errorf("got tag %s, want the existing referrer %s", desc.Digest, index.Manifests[0].Digest)
+	}
+}
+
+func TestPutReportSkipped(t *testing.T) {
+	ctx := context.Background()
+	host := newTestRegistry(t)
+	subject := pushTestImage(t, host, "app")
+	input := loadFixture(t, "cyclonedx.json", subject)
+
+	var out bytes.Buffer
+	if err := putReferrer(ctx, strings.NewReader(input), &out, putOptions{}); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+	pushed := strings.TrimPrefix(strings.TrimSpace(out.String()), "pushed: ")
+
+	opts := putOptions{annotations: map[string]string{"org.example.run": "2"}}
+	out.Reset()
+	if err := putReferrer(ctx, strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+	if got, want := out.String(), "exists: "+pushed+"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	opts.output = outputFormatJSON
+	out.Reset()
+	if err := putReferrer(ctx, strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("error putting referrer: %s", err)
+	}
+	var result putResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("error parsing result: %s", err)
+	}
+	if result.Reference != pushed || !result.Skipped {
+		t.Errorf("got reference %s and skipped %t, want %s and true", result.Reference, result.Skipped, pushed)
+	}
+}
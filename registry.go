@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+func addRegistryFlags(cmd *cobra.Command) {
+	cmd.Flags().String("username", "", "username for the registry")
+	cmd.Flags().String("password", "", "password for the registry")
+	cmd.Flags().Bool("password-stdin", false, "read the password for the registry from the standard input")
+	cmd.Flags().String("registry-token", "", "bearer token for the registry")
+	cmd.Flags().String("credential-helper", "", "docker credential helper to resolve the credentials with (e.g. ecr-login, docker-credential-gcr)")
+	cmd.Flags().String("keychain", referrer.KeychainDefault, "how to resolve the credentials for the registry (default, anonymous, env). env reads REGISTRY_USERNAME and REGISTRY_PASSWORD.")
+	cmd.Flags().String("docker-config", "", "directory of the docker config.json to read the credentials from (default ~/.docker)")
+	cmd.Flags().Bool("insecure", false, "allow plain HTTP and skip TLS verification for the registry")
+	cmd.Flags().StringArray("allow-insecure-registry", nil, "registry host to access over plain HTTP, such as localhost:5000, keeping TLS verification for the others. Can be specified multiple times.")
+	cmd.Flags().StringArray("cacert", nil, "PEM file of the CA certificates to trust for the registry in addition to the system ones. Can be specified multiple times.")
+	cmd.Flags().String("client-cert", "", "PEM file of the client certificate for the registry requiring mutual TLS")
+	cmd.Flags().String("client-key", "", "PEM file of the private key of the client certificate")
+	cmd.Flags().Bool("no-proxy", false, "connect to the registry directly, ignoring HTTP_PROXY, HTTPS_PROXY and NO_PROXY")
+	cmd.Flags().Int("max-retries", referrer.DefaultMaxRetries, "maximum number of retries for the registry operations on network errors and 429/5xx responses")
+	cmd.Flags().Duration("retry-delay", referrer.DefaultRetryDelay, "base delay of the exponential backoff between retries")
+	cmd.Flags().Duration("timeout", 0, "timeout for the registry operations (e.g. 30s, 5m). No timeout if not specified.")
+}
+
+func registryOptionsFromFlags(cmd *cobra.Command) (referrer.RegistryOptions, error) {
+	username, err := cmd.Flags().GetString("username")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting username: %w", err)
+	}
+
+	password, err := cmd.Flags().GetString("password")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting password: %w", err)
+	}
+
+	passwordStdin, err := cmd.Flags().GetBool("password-stdin")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting password-stdin flag: %w", err)
+	}
+
+	if passwordStdin {
+		if password != "" {
+			return referrer.RegistryOptions{}, fmt.Errorf("--password and --password-stdin are mutually exclusive")
+		}
+
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return referrer.RegistryOptions{}, fmt.Errorf("error reading password from stdin: %w", err)
+		}
+		password = strings.TrimRight(string(b), "\r\n")
+	}
+
+	token, err := cmd.Flags().GetString("registry-token")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting registry token: %w", err)
+	}
+
+	dockerConfig, err := cmd.Flags().GetString("docker-config")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting docker-config: %w", err)
+	}
+
+	credentialHelper, err := cmd.Flags().GetString("credential-helper")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting credential-helper: %w", err)
+	}
+
+	keychain, err := cmd.Flags().GetString("keychain")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting keychain: %w", err)
+	}
+	switch keychain {
+	case referrer.KeychainDefault:
+	case referrer.KeychainAnonymous, referrer.KeychainEnv:
+		if username != "" || token != "" || credentialHelper != "" || dockerConfig != "" {
+			return referrer.RegistryOptions{}, fmt.Errorf("--keychain %s can't be used with the other credential flags", keychain)
+		}
+		if keychain == referrer.KeychainEnv && os.Getenv("REGISTRY_USERNAME") == "" {
+			return referrer.RegistryOptions{}, fmt.Errorf("REGISTRY_USERNAME is required with --keychain env")
+		}
+	default:
+		return referrer.RegistryOptions{}, fmt.Errorf("unsupported keychain: %s", keychain)
+	}
+
+	insecure, err := cmd.Flags().GetBool("insecure")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting insecure flag: %w", err)
+	}
+
+	insecureRegistries, err := cmd.Flags().GetStringArray("allow-insecure-registry")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting allow-insecure-registry: %w", err)
+	}
+
+	cacerts, err := cmd.Flags().GetStringArray("cacert")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting cacert: %w", err)
+	}
+	var rootCAs *x509.CertPool
+	if len(cacerts) > 0 {
+		rootCAs, err = loadCACerts(cacerts)
+		if err != nil {
+			return referrer.RegistryOptions{}, err
+		}
+	}
+
+	clientCert, err := cmd.Flags().GetString("client-cert")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting client-cert: %w", err)
+	}
+	clientKey, err := cmd.Flags().GetString("client-key")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting client-key: %w", err)
+	}
+	if (clientCert == "") != (clientKey == "") {
+		return referrer.RegistryOptions{}, fmt.Errorf("--client-cert and --client-key must be given together")
+	}
+	var certificates []tls.Certificate
+	if clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return referrer.RegistryOptions{}, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		certificates = append(certificates, cert)
+	}
+
+	noProxy, err := cmd.Flags().GetBool("no-proxy")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting no-proxy flag: %w", err)
+	}
+
+	maxRetries, err := cmd.Flags().GetInt("max-retries")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting max-retries: %w", err)
+	}
+	if maxRetries < 0 {
+		return referrer.RegistryOptions{}, fmt.Errorf("--max-retries must not be negative")
+	}
+
+	retryDelay, err := cmd.Flags().GetDuration("retry-delay")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting retry-delay: %w", err)
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return referrer.RegistryOptions{}, fmt.Errorf("error getting timeout: %w", err)
+	}
+
+	if password != "" && username == "" {
+		return referrer.RegistryOptions{}, fmt.Errorf("--username is required when a password is given")
+	}
+	if token != "" && (username != "" || password != "") {
+		return referrer.RegistryOptions{}, fmt.Errorf("--registry-token and --username/--password are mutually exclusive")
+	}
+
+	return referrer.RegistryOptions{
+		Username:           username,
+		Password:           password,
+		Token:              token,
+		DockerConfig:       dockerConfig,
+		CredentialHelper:   credentialHelper,
+		Keychain:           keychain,
+		Insecure:           insecure,
+		InsecureRegistries: insecureRegistries,
+		RootCAs:            rootCAs,
+		Certificates:       certificates,
+		NoProxy:            noProxy,
+
+		MaxRetries: maxRetries,
+		RetryDelay: retryDelay,
+		Timeout:    timeout,
+	}, nil
+}
+
+// loadCACerts returns the system certificate pool with the CA certificates in the PEM files appended.
+func loadCACerts(paths []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no PEM certificate found in %s", path)
+		}
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// newTestRegistry starts an in-memory registry with the referrers API and returns its host.
+// The loopback host is accessed over plain HTTP.
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0)), registry.WithReferrersSupport(true)))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+// pushTestImage pushes a random image to the repository as the latest tag and returns its digest reference.
+func pushTestImage(t *testing.T, host, repo string) name.Digest {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatalf("error creating image: %s", err)
+	}
+
+	tag, err := name.NewTag(host + "/" + repo + ":latest")
+	if err != nil {
+		t.Fatalf("error parsing tag: %s", err)
+	}
+	if err := remote.Write(tag, img); err != nil {
+		t.Fatalf("error pushing image: %s", err)
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatalf("error getting digest: %s", err)
+	}
+	return tag.Context().Digest(digest.String())
+}
+
+// loadFixture reads the file in the testdata of pkg/referrer, replacing {{registry}} and {{digest}} with the ones of the subject.
+func loadFixture(t *testing.T, file string, subject name.Digest) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("pkg", "referrer", "testdata", file))
+	if err != nil {
+		t.Fatalf("error reading fixture: %s", err)
+	}
+
+	return strings.NewReplacer(
+		"{{registry}}", subject.RegistryStr(),
+		"{{digest}}", subject.DigestStr(),
+	).Replace(string(b))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aquasecurity/trivy-plugin-referrer/pkg/referrer"
+)
+
+// validateReferrer builds the referrer as put does and reports the detected format and the subject, without pushing it.
+// The registry is only read to resolve the subject.
+func validateReferrer(ctx context.Context, r io.Reader, w io.Writer, opts referrer.Options) error {
+	ref, err := referrer.BuildReferrer(ctx, r, opts)
+	if err != nil {
+		return fmt.Errorf("the input is not attachable: %w", &inputError{err: err})
+	}
+
+	fmt.Fprintf(w, "format: %s (%s)\n", ref.MediaType, ref.Annotations[referrer.AnnotationKeyDescription])
+	fmt.Fprintf(w, "repository: %s\n", ref.TargetRepo.Context().String())
+	fmt.Fprintf(w, "subject: %s\n", ref.TargetRepo.String())
+	fmt.Fprintf(w, "subject media type: %s\n", ref.TargetDesc.MediaType)
+
+	return nil
+}
+
+func validateReferrerFromPath(ctx context.Context, path string, w io.Writer, opts referrer.Options) error {
+	rc, err := openInput(ctx, path, opts.MaxSize)
+	if err != nil {
+		return fmt.Errorf("error opening input: %w", &inputError{err: err})
+	}
+	defer rc.Close()
+
+	return validateReferrer(ctx, rc, w, opts)
+}
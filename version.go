@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=...".
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// buildCommit returns the git commit of the build,
+// falling back to the one recorded by the Go toolchain when it isn't set with -ldflags.
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "Version: %s\n", version)
+	fmt.Fprintf(w, "Commit: %s\n", buildCommit())
+	fmt.Fprintf(w, "Go version: %s\n", runtime.Version())
+}